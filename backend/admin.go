@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/mukesh1352/splitwise-backend/db"
+	"github.com/mukesh1352/splitwise-backend/ledger"
+)
+
+// runAdmin handles the maintenance subcommands. They run against the same
+// database as the server and exit instead of serving HTTP:
+//
+//	server migrate ...       manage the database schema, see runMigrate
+//	server verify-balances   report balances that disagree with history
+//	server rebuild-balances  recompute the balances table from history
+func runAdmin(ctx context.Context, st *storage, l *ledger.Ledger, args []string) error {
+	switch args[0] {
+	case "migrate":
+		return runMigrate(ctx, st, args[1:])
+
+	case "verify-balances":
+		discrepancies, err := l.VerifyBalances(ctx)
+		if err != nil {
+			return err
+		}
+		printDiscrepancies(discrepancies)
+		if len(discrepancies) > 0 {
+			return fmt.Errorf("%d balance(s) disagree with history; run rebuild-balances to fix", len(discrepancies))
+		}
+		fmt.Println("balances match expense and settlement history")
+		return nil
+
+	case "rebuild-balances":
+		changed, err := l.RebuildBalances(ctx)
+		if err != nil {
+			return err
+		}
+		printDiscrepancies(changed)
+		fmt.Printf("balances rebuilt, %d pair(s) changed\n", len(changed))
+		return nil
+
+	default:
+		return fmt.Errorf("unknown command %q (expected migrate, verify-balances or rebuild-balances)", args[0])
+	}
+}
+
+// runMigrate applies or reverts the embedded schema migrations:
+//
+//	server migrate [up]      apply every pending migration
+//	server migrate down [N]  revert the last N migrations (default 1)
+//	server migrate status    list migrations and whether they are applied
+//	server migrate force V   record migrations up to V as applied without
+//	                         running them, e.g. for a schema created by hand
+func runMigrate(ctx context.Context, st *storage, args []string) error {
+	if st.db == nil {
+		return errors.New("the memory store has no schema to migrate")
+	}
+
+	command := "up"
+	if len(args) > 0 {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "up":
+		applied, err := db.Up(ctx, st.db, st.dialect)
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		fmt.Printf("schema up to date, %d migration(s) applied\n", len(applied))
+		return nil
+
+	case "down":
+		steps := 1
+		if len(args) > 0 {
+			n, err := strconv.Atoi(args[0])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid number of migrations %q", args[0])
+			}
+			steps = n
+		}
+		reverted, err := db.Down(ctx, st.db, st.dialect, steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		return err
+
+	case "status":
+		status, err := db.Status(ctx, st.db, st.dialect)
+		if err != nil {
+			return err
+		}
+		for _, m := range status {
+			state := "pending"
+			if m.Applied {
+				state = "applied"
+			}
+			fmt.Printf("%04d_%s\t%s\n", m.Version, m.Name, state)
+		}
+		return nil
+
+	case "force":
+		if len(args) == 0 {
+			return errors.New("migrate force needs a version")
+		}
+		version, err := strconv.Atoi(args[0])
+		if err != nil || version < 0 {
+			return fmt.Errorf("invalid version %q", args[0])
+		}
+		if err := db.Force(ctx, st.db, st.dialect, version); err != nil {
+			return err
+		}
+		fmt.Printf("schema recorded at version %d\n", version)
+		return nil
+
+	default:
+		return fmt.Errorf("unknown migrate command %q (expected up, down, status or force)", command)
+	}
+}
+
+// printDiscrepancies writes one line per pair. Amounts are signed: positive
+// means from owes to.
+func printDiscrepancies(discrepancies []ledger.BalanceDiscrepancy) {
+	for _, d := range discrepancies {
+		scope := d.GroupID
+		if scope == "" {
+			scope = "direct"
+		}
+		fmt.Printf("%s\t%s -> %s\texpected %s %s\tactual %s %s\n",
+			scope, d.FromUserID, d.ToUserID, d.Expected, d.Currency, d.Actual, d.Currency)
+	}
+}
@@ -0,0 +1,107 @@
+// Package api serves the ledger over HTTP as JSON.
+//
+// Routes are method-and-path patterns such as GET /groups/{id}/members. A
+// path served under other methods answers 405 with an Allow header, and an
+// unknown path 404, both in the usual error envelope. The query-string
+// routes of earlier versions are kept as deprecated aliases.
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/mukesh1352/splitwise-backend/ledger"
+)
+
+// server holds what the handlers share.
+type server struct {
+	ledger *ledger.Ledger
+	mux    *http.ServeMux
+}
+
+// NewHandler returns the API for l. Every request runs with timeout as its
+// deadline.
+func NewHandler(l *ledger.Ledger, timeout time.Duration) http.Handler {
+	s := &server{ledger: l, mux: http.NewServeMux()}
+	for _, rt := range s.routes() {
+		s.mux.Handle(rt.method+" "+rt.path, rt.handler)
+	}
+	return enableCORS(withTimeout(s, timeout))
+}
+
+// ServeHTTP answers requests no route matches itself, so that 404s and
+// 405s carry the error envelope rather than the mux's plain text.
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := s.mux.Handler(r); pattern == "" {
+		s.noRoute(w, r)
+		return
+	}
+	s.mux.ServeHTTP(w, r)
+}
+
+// routeMethods are the methods tried when looking for the ones a path
+// allows.
+var routeMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete,
+}
+
+func (s *server) noRoute(w http.ResponseWriter, r *http.Request) {
+	var allow []string
+	for _, method := range routeMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := s.mux.Handler(probe); pattern != "" {
+			allow = append(allow, method)
+			if method == http.MethodGet {
+				allow = append(allow, http.MethodHead)
+			}
+		}
+	}
+	if len(allow) == 0 {
+		writeErrorBody(w, http.StatusNotFound, errorBody{
+			Code:    "not_found",
+			Message: "no such endpoint",
+		})
+		return
+	}
+	w.Header()["Allow"] = allow
+	writeErrorBody(w, http.StatusMethodNotAllowed, errorBody{
+		Code:    "method_not_allowed",
+		Message: r.Method + " is not allowed here",
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func enableCORS(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE, OPTIONS")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Idempotency-Key")
+		w.Header().Set("Access-Control-Expose-Headers", "Deprecation, Link")
+
+		if r.Method == http.MethodOptions {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// withTimeout gives every request a deadline. The request context is also
+// cancelled when the client disconnects, and the ledger stops its database
+// work in either case.
+func withTimeout(next http.Handler, timeout time.Duration) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
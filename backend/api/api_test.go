@@ -0,0 +1,141 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mukesh1352/splitwise-backend/ledger"
+)
+
+func newTestHandler(t *testing.T) http.Handler {
+	t.Helper()
+	store := ledger.NewMemoryStore()
+	for _, id := range []string{"u1", "u2"} {
+		if err := store.AddUser(id, "User "+id, id+"@test.com"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.AddGroup("g1", "Trip", ledger.DefaultCurrency); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"u1", "u2"} {
+		if err := store.AddGroupMember("g1", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return NewHandler(ledger.NewWithStore(store), time.Second)
+}
+
+func serve(h http.Handler, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+	return rec
+}
+
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var resp errorResponse
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("error body is not the JSON envelope: %v", err)
+	}
+	return resp.Error.Code
+}
+
+func TestPathRoutes(t *testing.T) {
+	h := newTestHandler(t)
+
+	rec := serve(h, "GET", "/groups/g1/members", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /groups/g1/members = %d: %s", rec.Code, rec.Body)
+	}
+	var members []ledger.UserView
+	if err := json.NewDecoder(rec.Body).Decode(&members); err != nil || len(members) != 2 {
+		t.Errorf("members = %v, %v; want two members", members, err)
+	}
+
+	rec = serve(h, "PUT", "/groups/g1/simplify-debts", `{"enabled": true}`)
+	if rec.Code != http.StatusOK {
+		t.Errorf("PUT /groups/g1/simplify-debts = %d: %s", rec.Code, rec.Body)
+	}
+
+	rec = serve(h, "DELETE", "/users/u9", "")
+	if rec.Code != http.StatusNotFound || errorCode(t, rec) != "user_not_found" {
+		t.Errorf("DELETE /users/u9 = %d, want 404 user_not_found", rec.Code)
+	}
+
+	rec = serve(h, "GET", "/groups/g9/balances", "")
+	if rec.Code != http.StatusNotFound || errorCode(t, rec) != "group_not_found" {
+		t.Errorf("GET /groups/g9/balances = %d, want 404 group_not_found", rec.Code)
+	}
+}
+
+func TestWrongMethod_Is405WithAllow(t *testing.T) {
+	h := newTestHandler(t)
+
+	rec := serve(h, "DELETE", "/groups/g1/members", "")
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("DELETE /groups/g1/members = %d, want 405", rec.Code)
+	}
+	if got, want := strings.Join(rec.Header().Values("Allow"), ", "), "GET, HEAD, POST"; got != want {
+		t.Errorf("Allow = %q, want %q", got, want)
+	}
+	if code := errorCode(t, rec); code != "method_not_allowed" {
+		t.Errorf("code = %q, want method_not_allowed", code)
+	}
+
+	rec = serve(h, "GET", "/nowhere", "")
+	if rec.Code != http.StatusNotFound || errorCode(t, rec) != "not_found" {
+		t.Errorf("GET /nowhere = %d, want 404 not_found", rec.Code)
+	}
+}
+
+func TestDeprecatedAliases(t *testing.T) {
+	h := newTestHandler(t)
+
+	rec := serve(h, "GET", "/groups/members?group_id=g1", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /groups/members = %d: %s", rec.Code, rec.Body)
+	}
+	if rec.Header().Get("Deprecation") != "true" ||
+		rec.Header().Get("Link") != `</groups/{id}/members>; rel="successor-version"` {
+		t.Errorf("missing deprecation headers: %v", rec.Header())
+	}
+
+	rec = serve(h, "GET", "/balances/user", "")
+	if rec.Code != http.StatusBadRequest || errorCode(t, rec) != "validation_failed" {
+		t.Errorf("GET /balances/user without user_id = %d, want 400", rec.Code)
+	}
+
+	// routes introduced alongside their path forms have no aliases
+	rec = serve(h, "GET", "/balances/groups/simplify?group_id=g1", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /balances/groups/simplify = %d, want 404", rec.Code)
+	}
+
+	rec = serve(h, "POST", "/settle", `{"group_id": "g1", "from_user_id": "u2", "to_user_id": "u1", "amount": "1", "currency": "INR"}`)
+	if rec.Code != http.StatusUnprocessableEntity || errorCode(t, rec) != "no_outstanding_balance" {
+		t.Errorf("POST /settle = %d, want 422 no_outstanding_balance", rec.Code)
+	}
+}
+
+func TestCreateExpense_UnallocatableSplitIs400(t *testing.T) {
+	h := newTestHandler(t)
+
+	// 0.00001% is below the four decimal places percentages are kept to
+	rec := serve(h, "POST", "/expenses", `{
+		"expense_id": "e1", "group_id": "g1", "paid_by": "u1",
+		"total_amount": "100.00", "split_type": "PERCENT",
+		"participants": ["u1", "u2"],
+		"splits": [
+			{"user_id": "u1", "percentage": 99.99999},
+			{"user_id": "u2", "percentage": 0.00001}
+		]
+	}`)
+	if rec.Code != http.StatusBadRequest || errorCode(t, rec) != "validation_failed" {
+		t.Errorf("POST /expenses = %d: %s, want 400 validation_failed", rec.Code, rec.Body)
+	}
+}
@@ -0,0 +1,31 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/mukesh1352/splitwise-backend/ledger"
+)
+
+// deprecated marks responses from an alias kept for older clients with a
+// Deprecation header and a Link to the route that replaces it.
+func deprecated(successor string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+successor+`>; rel="successor-version"`)
+		next(w, r)
+	}
+}
+
+// idFromQuery serves a query-string alias with the handler of its path
+// route, passing the query parameter on as the {id} path value.
+func idFromQuery(param string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := r.URL.Query().Get(param)
+		if id == "" {
+			writeError(w, ledger.NewValidationError(param, param+" is required"))
+			return
+		}
+		r.SetPathValue("id", id)
+		next(w, r)
+	}
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"github.com/mukesh1352/splitwise-backend/ledger"
+)
+
+// errorResponse is the body of every failed request:
+//
+//	{"error": {"code": "validation_failed", "message": "...",
+//	           "fields": [{"field": "participants", "message": "...", "user_ids": [...]}]}}
+//
+// Code is stable and meant for programs; message is for people and may
+// change.
+type errorResponse struct {
+	Error errorBody `json:"error"`
+}
+
+type errorBody struct {
+	Code    string              `json:"code"`
+	Message string              `json:"message"`
+	Fields  []ledger.FieldError `json:"fields,omitempty"`
+}
+
+// kindStatus maps the ledger's error kinds to HTTP statuses.
+var kindStatus = []struct {
+	kind   error
+	status int
+}{
+	{ledger.ErrValidation, http.StatusBadRequest},
+	{ledger.ErrNotFound, http.StatusNotFound},
+	{ledger.ErrConflict, http.StatusConflict},
+	{ledger.ErrInsufficientBalance, http.StatusUnprocessableEntity},
+}
+
+// writeError reports a failed request. Ledger errors keep their code,
+// message and fields. A transaction abandoned after repeated conflicts
+// with concurrent writes is a 409 with a Retry-After hint, and a request
+// cut off by its deadline a 504. Anything else is logged and reported as
+// a bare 500, so database errors never reach the client.
+func writeError(w http.ResponseWriter, err error) {
+	var ledgerErr *ledger.Error
+	switch {
+	case errors.Is(err, ledger.ErrSerialization):
+		w.Header().Set("Retry-After", "1")
+		writeErrorBody(w, http.StatusConflict, errorBody{
+			Code:    "serialization_failure",
+			Message: "the request collided with concurrent updates, please retry",
+		})
+	case errors.As(err, &ledgerErr):
+		status := http.StatusInternalServerError
+		for _, k := range kindStatus {
+			if errors.Is(ledgerErr, k.kind) {
+				status = k.status
+				break
+			}
+		}
+		writeErrorBody(w, status, errorBody{
+			Code:    ledgerErr.Code,
+			Message: ledgerErr.Message,
+			Fields:  ledgerErr.Fields,
+		})
+	case errors.Is(err, context.DeadlineExceeded):
+		writeErrorBody(w, http.StatusGatewayTimeout, errorBody{
+			Code:    "timeout",
+			Message: "the request took too long",
+		})
+	default:
+		log.Printf("internal error: %v", err)
+		writeErrorBody(w, http.StatusInternalServerError, errorBody{
+			Code:    "internal_error",
+			Message: "internal server error",
+		})
+	}
+}
+
+func writeErrorBody(w http.ResponseWriter, status int, body errorBody) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(errorResponse{Error: body})
+}
+
+// invalidBody reports a request body that is not the JSON expected.
+func invalidBody(err error) error {
+	return ledger.NewValidationError("body", "invalid request body: "+err.Error())
+}
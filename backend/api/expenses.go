@@ -0,0 +1,125 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/mukesh1352/splitwise-backend/ledger"
+)
+
+// create an expense
+func (s *server) createExpense(w http.ResponseWriter, r *http.Request) {
+	var input ledger.ExpenseInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, invalidBody(err))
+		return
+	}
+	// an Idempotency-Key stands in for a missing expense_id
+	if key := r.Header.Get("Idempotency-Key"); key != "" && input.ExpenseID == "" {
+		input.ExpenseID = ledger.IDFromIdempotencyKey(key)
+	}
+	if err := s.ledger.CreateExpense(r.Context(), input); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, map[string]string{
+		"status":     "Expense created successfully..",
+		"expense_id": input.ExpenseID,
+	})
+}
+
+// list expenses, newest first
+func (s *server) listExpenses(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := ledger.ExpenseFilter{
+		GroupID:       q.Get("group_id"),
+		PaidBy:        q.Get("paid_by"),
+		ParticipantID: q.Get("participant_id"),
+		Cursor:        q.Get("cursor"),
+	}
+	var err error
+	if filter.From, filter.To, filter.Limit, err = parsePageParams(q); err != nil {
+		writeError(w, err)
+		return
+	}
+	page, err := s.ledger.ListExpenses(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+// get a single expense with its splits
+func (s *server) getExpense(w http.ResponseWriter, r *http.Request) {
+	expense, err := s.ledger.GetExpense(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, expense)
+}
+
+// update an expense
+func (s *server) updateExpense(w http.ResponseWriter, r *http.Request) {
+	expenseID := r.PathValue("id")
+	var input ledger.ExpenseInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, invalidBody(err))
+		return
+	}
+	if input.ExpenseID != "" && input.ExpenseID != expenseID {
+		writeError(w, ledger.NewValidationError("expense_id", "expense_id does not match the URL"))
+		return
+	}
+	if err := s.ledger.UpdateExpense(r.Context(), expenseID, input); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "Expense updated successfully..",
+	})
+}
+
+// delete an expense
+func (s *server) deleteExpense(w http.ResponseWriter, r *http.Request) {
+	if err := s.ledger.DeleteExpense(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "Expense deleted successfully..",
+	})
+}
+
+// parsePageParams reads the from, to and limit parameters shared by the
+// history listings.
+func parsePageParams(q url.Values) (from, to time.Time, limit int, err error) {
+	if from, err = parseTimeParam(q.Get("from")); err != nil {
+		return from, to, 0, ledger.NewValidationError("from", "invalid from: "+err.Error())
+	}
+	if to, err = parseTimeParam(q.Get("to")); err != nil {
+		return from, to, 0, ledger.NewValidationError("to", "invalid to: "+err.Error())
+	}
+	if value := q.Get("limit"); value != "" {
+		if limit, err = strconv.Atoi(value); err != nil {
+			return from, to, 0, ledger.NewValidationError("limit", "invalid limit")
+		}
+	}
+	return from, to, limit, nil
+}
+
+// parseTimeParam accepts an RFC 3339 timestamp or a plain date
+// (2006-01-02). An empty value yields the zero time.
+func parseTimeParam(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.DateOnly, value)
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mukesh1352/splitwise-backend/ledger"
+)
+
+// list groups that are not archived
+func (s *server) listGroups(w http.ResponseWriter, r *http.Request) {
+	groups, err := s.ledger.GetGroups(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, groups)
+}
+
+// create a group with its first members
+func (s *server) createGroup(w http.ResponseWriter, r *http.Request) {
+	var input ledger.GroupInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, invalidBody(err))
+		return
+	}
+	group, err := s.ledger.CreateGroup(r.Context(), input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, group)
+}
+
+// rename a group
+func (s *server) renameGroup(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Name string `json:"name"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, invalidBody(err))
+		return
+	}
+	if err := s.ledger.RenameGroup(r.Context(), r.PathValue("id"), request.Name); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "Group renamed successfully..",
+	})
+}
+
+// archive a settled-up group
+func (s *server) archiveGroup(w http.ResponseWriter, r *http.Request) {
+	if err := s.ledger.ArchiveGroup(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "Group archived successfully..",
+	})
+}
+
+// list a group's members
+func (s *server) listGroupMembers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.ledger.GetGroupMembers(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+// add a member to a group
+func (s *server) addGroupMember(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		UserID string `json:"user_id"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, invalidBody(err))
+		return
+	}
+	if err := s.ledger.AddGroupMember(r.Context(), r.PathValue("id"), request.UserID); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "Member added successfully..",
+	})
+}
+
+// remove a member with no outstanding balances in the group
+func (s *server) removeGroupMember(w http.ResponseWriter, r *http.Request) {
+	if err := s.ledger.RemoveGroupMember(r.Context(), r.PathValue("id"), r.PathValue("user_id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "Member removed successfully..",
+	})
+}
+
+// balances within a group
+func (s *server) groupBalances(w http.ResponseWriter, r *http.Request) {
+	balances, err := s.ledger.GetGroupBalances(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, balances)
+}
+
+// preview the minimal set of balances for a group
+func (s *server) previewSimplification(w http.ResponseWriter, r *http.Request) {
+	balances, err := s.ledger.PreviewGroupSimplification(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, balances)
+}
+
+// replace a group's balances with the minimal set
+func (s *server) simplifyBalances(w http.ResponseWriter, r *http.Request) {
+	balances, err := s.ledger.SimplifyGroupBalances(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, balances)
+}
+
+// turn automatic debt simplification on or off for a group
+func (s *server) setSimplifyDebts(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		Enabled bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, invalidBody(err))
+		return
+	}
+	if err := s.ledger.SetGroupSimplifyDebts(r.Context(), r.PathValue("id"), request.Enabled); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{
+		"status":         "Group setting updated..",
+		"simplify_debts": request.Enabled,
+	})
+}
@@ -0,0 +1,55 @@
+package api
+
+import "net/http"
+
+// route is one method and path pattern with its handler.
+type route struct {
+	method  string
+	path    string
+	handler http.HandlerFunc
+}
+
+func (s *server) routes() []route {
+	return []route{
+		// users
+		{"GET", "/users", s.listUsers},
+		{"POST", "/users", s.createUser},
+		{"PATCH", "/users/{id}", s.updateUser},
+		{"DELETE", "/users/{id}", s.deactivateUser},
+		{"GET", "/users/{id}/balances", s.userBalances},
+		{"GET", "/users/{id}/balances/aggregated", s.aggregatedBalances},
+
+		// groups
+		{"GET", "/groups", s.listGroups},
+		{"POST", "/groups", s.createGroup},
+		{"PATCH", "/groups/{id}", s.renameGroup},
+		{"POST", "/groups/{id}/archive", s.archiveGroup},
+		{"GET", "/groups/{id}/members", s.listGroupMembers},
+		{"POST", "/groups/{id}/members", s.addGroupMember},
+		{"DELETE", "/groups/{id}/members/{user_id}", s.removeGroupMember},
+		{"GET", "/groups/{id}/balances", s.groupBalances},
+		{"GET", "/groups/{id}/balances/simplified", s.previewSimplification},
+		{"POST", "/groups/{id}/balances/simplify", s.simplifyBalances},
+		{"PUT", "/groups/{id}/simplify-debts", s.setSimplifyDebts},
+
+		// expenses
+		{"GET", "/expenses", s.listExpenses},
+		{"POST", "/expenses", s.createExpense},
+		{"GET", "/expenses/{id}", s.getExpense},
+		{"PUT", "/expenses/{id}", s.updateExpense},
+		{"DELETE", "/expenses/{id}", s.deleteExpense},
+
+		// settlements
+		{"GET", "/settlements", s.listSettlements},
+		{"POST", "/settlements", s.createSettlement},
+
+		// deprecated aliases, see deprecated.go
+		{"GET", "/balances/user", deprecated("/users/{id}/balances",
+			idFromQuery("user_id", s.userBalances))},
+		{"GET", "/balances/groups", deprecated("/groups/{id}/balances",
+			idFromQuery("group_id", s.groupBalances))},
+		{"GET", "/groups/members", deprecated("/groups/{id}/members",
+			idFromQuery("group_id", s.listGroupMembers))},
+		{"POST", "/settle", deprecated("/settlements", s.createSettlement)},
+	}
+}
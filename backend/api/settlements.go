@@ -0,0 +1,75 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+
+	"github.com/mukesh1352/splitwise-backend/ledger"
+)
+
+// record a settlement
+func (s *server) createSettlement(w http.ResponseWriter, r *http.Request) {
+	var request struct {
+		SettlementID string       `json:"settlement_id"`
+		GroupID      string       `json:"group_id"`
+		FromUserID   string       `json:"from_user_id"`
+		ToUserID     string       `json:"to_user_id"`
+		Amount       ledger.Money `json:"amount"`
+		Currency     string       `json:"currency"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		writeError(w, invalidBody(err))
+		return
+	}
+	request.Amount.Currency = request.Currency
+
+	// retries carry the same settlement_id or Idempotency-Key and are
+	// answered with the original settlement instead of paying twice
+	settlementID := request.SettlementID
+	if key := r.Header.Get("Idempotency-Key"); key != "" && settlementID == "" {
+		settlementID = ledger.IDFromIdempotencyKey(key)
+	}
+	if settlementID == "" {
+		settlementID = uuid.NewString()
+	}
+	err := s.ledger.SettleBalanceWithID(
+		r.Context(),
+		settlementID,
+		request.GroupID,
+		request.FromUserID,
+		request.ToUserID,
+		request.Amount,
+	)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status":        "Settlement is recorded succesfully..",
+		"settlement_id": settlementID,
+	})
+}
+
+// settlement history, newest first
+func (s *server) listSettlements(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	filter := ledger.SettlementFilter{
+		GroupID:        q.Get("group_id"),
+		UserID:         q.Get("user_id"),
+		CounterpartyID: q.Get("counterparty_id"),
+		Cursor:         q.Get("cursor"),
+	}
+	var err error
+	if filter.From, filter.To, filter.Limit, err = parsePageParams(q); err != nil {
+		writeError(w, err)
+		return
+	}
+	page, err := s.ledger.ListSettlements(r.Context(), filter)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
@@ -0,0 +1,79 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/mukesh1352/splitwise-backend/ledger"
+)
+
+// list active users
+func (s *server) listUsers(w http.ResponseWriter, r *http.Request) {
+	users, err := s.ledger.GetUsers(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, users)
+}
+
+// create a user
+func (s *server) createUser(w http.ResponseWriter, r *http.Request) {
+	var input ledger.UserInput
+	if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
+		writeError(w, invalidBody(err))
+		return
+	}
+	user, err := s.ledger.CreateUser(r.Context(), input)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// change a user's name or email
+func (s *server) updateUser(w http.ResponseWriter, r *http.Request) {
+	var update ledger.UserUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		writeError(w, invalidBody(err))
+		return
+	}
+	user, err := s.ledger.UpdateUser(r.Context(), r.PathValue("id"), update)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// deactivate a user
+func (s *server) deactivateUser(w http.ResponseWriter, r *http.Request) {
+	if err := s.ledger.DeactivateUser(r.Context(), r.PathValue("id")); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"status": "User deactivated successfully..",
+	})
+}
+
+// a user's balances in every scope
+func (s *server) userBalances(w http.ResponseWriter, r *http.Request) {
+	balances, err := s.ledger.GetUserBalances(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, balances)
+}
+
+// a user's balances netted across all groups
+func (s *server) aggregatedBalances(w http.ResponseWriter, r *http.Request) {
+	balances, err := s.ledger.GetAggregatedBalances(r.Context(), r.PathValue("id"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, balances)
+}
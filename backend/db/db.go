@@ -1,24 +1,103 @@
-package main
+// Package db holds the database schema as versioned migrations embedded in
+// the binary, and applies them.
+//
+// Every supported database has its own directory under migrations, named
+// after its Dialect. A migration is a pair of files
+//
+//	NNNN_name.up.sql    applies the change
+//	NNNN_name.down.sql  reverts it
+//
+// and versions are applied in ascending order. A change to the schema is a
+// new migration with the same version and name in every directory; applied
+// migrations are never edited.
+//
+// Versions 1 to 7 are the schema the project started with, so a database
+// created from it before migrations existed is adopted with "migrate force
+// 7" and upgraded by the rest. SQLite support came after versions 8 to 16:
+// its first migrations create the tables in their current shape, and its
+// copies of those versions only create the tables they introduce.
+//
+// SQLite has no UUID, NUMERIC(p, s) or NULLS NOT DISTINCT, so its schema
+// stores IDs as TEXT, keeps amounts and exchange rates as decimal TEXT (a
+// NUMERIC column would turn them into binary floats), writes timestamps as
+// UTC text with millisecond precision, and makes balances unique per scope
+// with an index that treats a NULL group_id as the empty string.
+package db
 
 import (
-	"context"
-	"log"
-	"os"
-	"github.com/jackc/pgx/v5"
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
 )
 
-func main() {
-	conn, err := pgx.Connect(context.Background(), os.Getenv("DATABASE_URL"))
+// Dialect names a supported database.
+type Dialect string
+
+const (
+	Postgres Dialect = "postgres"
+	SQLite   Dialect = "sqlite"
+)
+
+//go:embed migrations
+var migrationFiles embed.FS
+
+// Migration is one versioned schema change.
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Migrations returns the dialect's migrations in version order.
+func Migrations(dialect Dialect) ([]Migration, error) {
+	dir := path.Join("migrations", string(dialect))
+	entries, err := fs.ReadDir(migrationFiles, dir)
 	if err != nil {
-		log.Fatalf("Failed to connect to the database: %v", err)
+		return nil, fmt.Errorf("no migrations for %q", dialect)
 	}
-	defer conn.Close(context.Background())
 
-	// Example query to test connection
-	var version string
-	if err := conn.QueryRow(context.Background(), "SELECT version()").Scan(&version); err != nil {
-		log.Fatalf("Query failed: %v", err)
+	byVersion := map[int]*Migration{}
+	for _, entry := range entries {
+		file := entry.Name()
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		number, name, named := strings.Cut(base, "_")
+		version, err := strconv.Atoi(number)
+		if !ok || !named || err != nil || version <= 0 || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("migration %s: name must be NNNN_name.up.sql or NNNN_name.down.sql", file)
+		}
+
+		content, err := fs.ReadFile(migrationFiles, path.Join(dir, file))
+		if err != nil {
+			return nil, err
+		}
+
+		m := byVersion[version]
+		if m == nil {
+			m = &Migration{Version: version, Name: name}
+			byVersion[version] = m
+		}
+		if m.Name != name {
+			return nil, fmt.Errorf("migration %d has two names: %s and %s", version, m.Name, name)
+		}
+		if direction == "up" {
+			m.Up = string(content)
+		} else {
+			m.Down = string(content)
+		}
 	}
 
-	log.Println("Connected to:", version)
-}
\ No newline at end of file
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migration %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
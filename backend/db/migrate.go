@@ -0,0 +1,219 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// createMigrationsTable records which migrations have been applied. It is
+// valid in every dialect.
+const createMigrationsTable = `
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER PRIMARY KEY,
+		name TEXT NOT NULL,
+		applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+	)`
+
+// lockMigrations keeps concurrent migrators, e.g. two servers starting
+// with auto-migrate, from applying the same migration twice. SQLite needs
+// no lock: a writing transaction already has the database to itself.
+var lockMigrations = map[Dialect]string{
+	Postgres: `LOCK TABLE schema_migrations IN EXCLUSIVE MODE`,
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+// Up applies every pending migration in version order, each in its own
+// transaction, and returns the ones it applied.
+func Up(ctx context.Context, conn *sql.DB, dialect Dialect) ([]Migration, error) {
+	migrations, err := Migrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, err
+	}
+
+	applied := []Migration{}
+	for _, m := range migrations {
+		ran, err := migrate(ctx, conn, dialect, m, true)
+		if err != nil {
+			return applied, err
+		}
+		if ran {
+			applied = append(applied, m)
+		}
+	}
+	return applied, nil
+}
+
+// Down reverts the last steps applied migrations, newest first, and returns
+// the ones it reverted.
+func Down(ctx context.Context, conn *sql.DB, dialect Dialect, steps int) ([]Migration, error) {
+	status, err := Status(ctx, conn, dialect)
+	if err != nil {
+		return nil, err
+	}
+
+	reverted := []Migration{}
+	for i := len(status) - 1; i >= 0 && len(reverted) < steps; i-- {
+		if !status[i].Applied {
+			continue
+		}
+		ran, err := migrate(ctx, conn, dialect, status[i].Migration, false)
+		if err != nil {
+			return reverted, err
+		}
+		if ran {
+			reverted = append(reverted, status[i].Migration)
+		}
+	}
+	return reverted, nil
+}
+
+// Status lists the dialect's migrations and whether each is applied.
+func Status(ctx context.Context, conn *sql.DB, dialect Dialect) ([]MigrationStatus, error) {
+	migrations, err := Migrations(dialect)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+		return nil, err
+	}
+
+	versions, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	for version := range versions {
+		if !known(migrations, version) {
+			return nil, fmt.Errorf("migration %d is applied but unknown to this binary", version)
+		}
+	}
+
+	status := make([]MigrationStatus, len(migrations))
+	for i, m := range migrations {
+		status[i] = MigrationStatus{Migration: m, Applied: versions[m.Version]}
+	}
+	return status, nil
+}
+
+// Force records migrations up to and including version as applied, and
+// later ones as not applied, without running any SQL. It adopts a database
+// whose schema was created by hand, or recovers from a migration that
+// failed halfway in a database without transactional DDL.
+func Force(ctx context.Context, conn *sql.DB, dialect Dialect, version int) error {
+	migrations, err := Migrations(dialect)
+	if err != nil {
+		return err
+	}
+	if version != 0 && !known(migrations, version) {
+		return fmt.Errorf("unknown migration version %d", version)
+	}
+	if _, err := conn.ExecContext(ctx, createMigrationsTable); err != nil {
+		return err
+	}
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM schema_migrations`); err != nil {
+		return err
+	}
+	for _, m := range migrations {
+		if m.Version > version {
+			break
+		}
+		if err := recordMigration(ctx, tx, m); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// migrate applies (up) or reverts one migration together with its
+// schema_migrations row. It reports false when another migrator got there
+// first.
+func migrate(ctx context.Context, conn *sql.DB, dialect Dialect, m Migration, up bool) (bool, error) {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if lock := lockMigrations[dialect]; lock != "" {
+		if _, err := tx.ExecContext(ctx, lock); err != nil {
+			return false, err
+		}
+	}
+
+	var applied bool
+	err = tx.QueryRowContext(ctx, `
+		SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)
+	`, m.Version).Scan(&applied)
+	if err != nil {
+		return false, err
+	}
+	if applied == up {
+		return false, nil
+	}
+
+	if up {
+		if _, err := tx.ExecContext(ctx, m.Up); err != nil {
+			return false, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		err = recordMigration(ctx, tx, m)
+	} else {
+		if _, err := tx.ExecContext(ctx, m.Down); err != nil {
+			return false, fmt.Errorf("reverting migration %d_%s: %w", m.Version, m.Name, err)
+		}
+		_, err = tx.ExecContext(ctx, `DELETE FROM schema_migrations WHERE version = $1`, m.Version)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+func recordMigration(ctx context.Context, tx *sql.Tx, m Migration) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO schema_migrations (version, name)
+		VALUES ($1, $2)
+	`, m.Version, m.Name)
+	return err
+}
+
+func appliedVersions(ctx context.Context, conn *sql.DB) (map[int]bool, error) {
+	rows, err := conn.QueryContext(ctx, `SELECT version FROM schema_migrations`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := map[int]bool{}
+	for rows.Next() {
+		var version int
+		if err := rows.Scan(&version); err != nil {
+			return nil, err
+		}
+		versions[version] = true
+	}
+	return versions, rows.Err()
+}
+
+func known(migrations []Migration, version int) bool {
+	for _, m := range migrations {
+		if m.Version == version {
+			return true
+		}
+	}
+	return false
+}
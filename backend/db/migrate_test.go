@@ -0,0 +1,122 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	_ "modernc.org/sqlite"
+)
+
+func openSQLite(t *testing.T) *sql.DB {
+	t.Helper()
+	conn, err := sql.Open("sqlite", "file::memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// every connection to :memory: is a separate database
+	conn.SetMaxOpenConns(1)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func TestMigrations_SameVersionsInEveryDialect(t *testing.T) {
+	postgres, err := Migrations(Postgres)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlite, err := Migrations(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(postgres) != len(sqlite) {
+		t.Fatalf("postgres has %d migrations, sqlite %d", len(postgres), len(sqlite))
+	}
+	for i := range postgres {
+		if postgres[i].Version != sqlite[i].Version || postgres[i].Name != sqlite[i].Name {
+			t.Errorf("migration %d: postgres %d_%s, sqlite %d_%s", i,
+				postgres[i].Version, postgres[i].Name, sqlite[i].Version, sqlite[i].Name)
+		}
+	}
+}
+
+func TestUpDown_SQLite(t *testing.T) {
+	ctx := context.Background()
+	conn := openSQLite(t)
+
+	all, err := Migrations(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := Up(ctx, conn, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(applied) != len(all) {
+		t.Fatalf("applied %d migrations, want %d", len(applied), len(all))
+	}
+
+	// a second run has nothing left to do
+	if applied, err := Up(ctx, conn, SQLite); err != nil || len(applied) != 0 {
+		t.Fatalf("second Up() = %d migrations, %v; want none", len(applied), err)
+	}
+
+	reverted, err := Down(ctx, conn, SQLite, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reverted) != 2 || reverted[0].Version != all[len(all)-1].Version {
+		t.Fatalf("Down() reverted %+v, want the last two migrations newest first", reverted)
+	}
+
+	status, err := Status(ctx, conn, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range status {
+		if want := i < len(all)-2; m.Applied != want {
+			t.Errorf("%04d_%s applied = %v, want %v", m.Version, m.Name, m.Applied, want)
+		}
+	}
+
+	// everything reverts cleanly back to an empty database
+	if _, err := Down(ctx, conn, SQLite, len(all)); err != nil {
+		t.Fatal(err)
+	}
+	var tables int
+	err = conn.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type = 'table' AND name <> 'schema_migrations'
+	`).Scan(&tables)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tables != 0 {
+		t.Errorf("%d tables left after reverting every migration", tables)
+	}
+}
+
+func TestForce_AdoptsExistingSchema(t *testing.T) {
+	ctx := context.Background()
+	conn := openSQLite(t)
+
+	all, err := Migrations(SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// a schema applied by hand, before schema_migrations existed
+	for _, m := range all {
+		if _, err := conn.ExecContext(ctx, m.Up); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := Force(ctx, conn, SQLite, all[len(all)-1].Version); err != nil {
+		t.Fatal(err)
+	}
+	if applied, err := Up(ctx, conn, SQLite); err != nil || len(applied) != 0 {
+		t.Errorf("Up() after Force() = %d migrations, %v; want none", len(applied), err)
+	}
+}
@@ -0,0 +1,2 @@
+ALTER TABLE groups ADD COLUMN remainder_strategy TEXT NOT NULL DEFAULT 'LARGEST_REMAINDER'
+    CHECK (remainder_strategy IN ('LARGEST_REMAINDER', 'PAYER_FIRST'));
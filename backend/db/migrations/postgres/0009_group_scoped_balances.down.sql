@@ -0,0 +1,8 @@
+ALTER TABLE settlements DROP COLUMN group_id;
+
+DROP INDEX balances_to_user_idx;
+DROP INDEX balances_from_user_idx;
+-- fails while a pair has balances in more than one scope
+ALTER TABLE balances DROP CONSTRAINT balances_scope_pair_key;
+ALTER TABLE balances DROP COLUMN group_id;
+ALTER TABLE balances ADD PRIMARY KEY (from_user_id, to_user_id);
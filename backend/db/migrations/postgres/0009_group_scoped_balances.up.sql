@@ -0,0 +1,17 @@
+-- Balances and settlements are scoped to a group; group_id IS NULL is the
+-- direct (non-group) scope, where rows recorded before this migration end
+-- up.
+ALTER TABLE balances ADD COLUMN group_id UUID REFERENCES groups(id) ON DELETE CASCADE;
+ALTER TABLE balances DROP CONSTRAINT balances_pkey;
+ALTER TABLE balances
+    ALTER COLUMN from_user_id SET NOT NULL,
+    ALTER COLUMN to_user_id SET NOT NULL;
+-- NULLS NOT DISTINCT keeps one row per pair in the direct scope as well
+-- (PostgreSQL 15+)
+ALTER TABLE balances ADD CONSTRAINT balances_scope_pair_key
+    UNIQUE NULLS NOT DISTINCT (group_id, from_user_id, to_user_id);
+
+CREATE INDEX balances_from_user_idx ON balances (from_user_id);
+CREATE INDEX balances_to_user_idx ON balances (to_user_id);
+
+ALTER TABLE settlements ADD COLUMN group_id UUID REFERENCES groups(id) ON DELETE CASCADE;
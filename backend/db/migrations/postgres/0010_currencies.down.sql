@@ -0,0 +1,8 @@
+ALTER TABLE settlements DROP COLUMN currency;
+ALTER TABLE balances DROP COLUMN currency;
+ALTER TABLE expense_splits DROP COLUMN base_amount;
+ALTER TABLE expenses
+    DROP COLUMN base_amount,
+    DROP COLUMN exchange_rate,
+    DROP COLUMN currency;
+ALTER TABLE groups DROP COLUMN base_currency;
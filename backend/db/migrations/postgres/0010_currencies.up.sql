@@ -0,0 +1,18 @@
+-- amounts recorded before this migration are in INR
+ALTER TABLE groups ADD COLUMN base_currency CHAR(3) NOT NULL DEFAULT 'INR';
+
+ALTER TABLE expenses
+    ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'INR',
+    -- units of the group's base currency per unit of currency
+    ADD COLUMN exchange_rate NUMERIC(20, 10) NOT NULL DEFAULT 1 CHECK (exchange_rate > 0),
+    -- amount converted into the group's base currency
+    ADD COLUMN base_amount NUMERIC(12, 2);
+UPDATE expenses SET base_amount = amount;
+ALTER TABLE expenses ALTER COLUMN base_amount SET NOT NULL;
+
+ALTER TABLE expense_splits ADD COLUMN base_amount NUMERIC(12, 2);
+UPDATE expense_splits SET base_amount = amount;
+
+-- the base currency of the group (or INR for the direct scope)
+ALTER TABLE balances ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'INR';
+ALTER TABLE settlements ADD COLUMN currency CHAR(3) NOT NULL DEFAULT 'INR';
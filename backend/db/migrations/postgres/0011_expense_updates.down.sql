@@ -0,0 +1 @@
+ALTER TABLE expenses DROP COLUMN updated_at;
@@ -0,0 +1 @@
+ALTER TABLE expenses ADD COLUMN updated_at TIMESTAMP;
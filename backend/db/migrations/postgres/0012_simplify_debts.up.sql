@@ -0,0 +1,2 @@
+-- keep balances at the minimal set of transfers after every write
+ALTER TABLE groups ADD COLUMN simplify_debts BOOLEAN NOT NULL DEFAULT FALSE;
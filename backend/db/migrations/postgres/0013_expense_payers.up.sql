@@ -0,0 +1,11 @@
+CREATE TABLE expense_payers (
+    expense_id UUID REFERENCES expenses(id) ON DELETE CASCADE,
+    user_id UUID REFERENCES users(id),
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    base_amount NUMERIC(12, 2) NOT NULL,
+    PRIMARY KEY (expense_id, user_id)
+);
+
+-- every expense recorded so far has its single payer in paid_by
+INSERT INTO expense_payers (expense_id, user_id, amount, base_amount)
+SELECT id, paid_by, amount, base_amount FROM expenses;
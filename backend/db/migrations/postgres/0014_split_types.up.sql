@@ -0,0 +1,3 @@
+ALTER TABLE expenses DROP CONSTRAINT expenses_split_type_check;
+ALTER TABLE expenses ADD CONSTRAINT expenses_split_type_check
+    CHECK (split_type IN ('EQUAL', 'EXACT', 'PERCENT', 'SHARES', 'ADJUSTMENT'));
@@ -0,0 +1,10 @@
+DROP TABLE expense_item_consumers;
+DROP TABLE expense_items;
+
+ALTER TABLE expenses
+    DROP COLUMN tip,
+    DROP COLUMN tax;
+
+ALTER TABLE expenses DROP CONSTRAINT expenses_split_type_check;
+ALTER TABLE expenses ADD CONSTRAINT expenses_split_type_check
+    CHECK (split_type IN ('EQUAL', 'EXACT', 'PERCENT', 'SHARES', 'ADJUSTMENT'));
@@ -0,0 +1,26 @@
+ALTER TABLE expenses DROP CONSTRAINT expenses_split_type_check;
+ALTER TABLE expenses ADD CONSTRAINT expenses_split_type_check
+    CHECK (split_type IN ('EQUAL', 'EXACT', 'PERCENT', 'SHARES', 'ADJUSTMENT', 'ITEMIZED'));
+
+-- receipt tax and tip of an ITEMIZED expense, included in amount
+ALTER TABLE expenses
+    ADD COLUMN tax NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (tax >= 0),
+    ADD COLUMN tip NUMERIC(12, 2) NOT NULL DEFAULT 0 CHECK (tip >= 0);
+
+CREATE TABLE expense_items (
+    expense_id UUID REFERENCES expenses(id) ON DELETE CASCADE,
+    -- order of the item on the receipt
+    position INT NOT NULL,
+    name TEXT NOT NULL,
+    amount NUMERIC(12, 2) NOT NULL CHECK (amount > 0),
+    PRIMARY KEY (expense_id, position)
+);
+
+CREATE TABLE expense_item_consumers (
+    expense_id UUID NOT NULL,
+    position INT NOT NULL,
+    user_id UUID REFERENCES users(id),
+    FOREIGN KEY (expense_id, position)
+        REFERENCES expense_items(expense_id, position) ON DELETE CASCADE,
+    PRIMARY KEY (expense_id, position, user_id)
+);
@@ -0,0 +1,2 @@
+-- fingerprint of the creating request, used to recognise retries
+ALTER TABLE expenses ADD COLUMN request_hash TEXT;
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN deactivated_at TIMESTAMP;
@@ -0,0 +1,5 @@
+ALTER TABLE users ALTER COLUMN created_at SET DEFAULT NOW();
+ALTER TABLE groups ALTER COLUMN created_at SET DEFAULT NOW();
+ALTER TABLE group_members ALTER COLUMN joined_at SET DEFAULT NOW();
+ALTER TABLE expenses ALTER COLUMN created_at SET DEFAULT NOW();
+ALTER TABLE settlements ALTER COLUMN created_at SET DEFAULT NOW();
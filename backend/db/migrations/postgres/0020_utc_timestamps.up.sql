@@ -0,0 +1,7 @@
+-- TIMESTAMP columns hold UTC. NOW() would be converted to the session's
+-- time zone before being stored without it.
+ALTER TABLE users ALTER COLUMN created_at SET DEFAULT (NOW() AT TIME ZONE 'UTC');
+ALTER TABLE groups ALTER COLUMN created_at SET DEFAULT (NOW() AT TIME ZONE 'UTC');
+ALTER TABLE group_members ALTER COLUMN joined_at SET DEFAULT (NOW() AT TIME ZONE 'UTC');
+ALTER TABLE expenses ALTER COLUMN created_at SET DEFAULT (NOW() AT TIME ZONE 'UTC');
+ALTER TABLE settlements ALTER COLUMN created_at SET DEFAULT (NOW() AT TIME ZONE 'UTC');
@@ -0,0 +1,10 @@
+CREATE TABLE groups (
+    id TEXT PRIMARY KEY,
+    name VARCHAR(100) NOT NULL,
+    base_currency CHAR(3) NOT NULL DEFAULT 'INR',
+    remainder_strategy TEXT NOT NULL DEFAULT 'LARGEST_REMAINDER'
+        CHECK (remainder_strategy IN ('LARGEST_REMAINDER', 'PAYER_FIRST')),
+    -- keep balances at the minimal set of transfers after every write
+    simplify_debts BOOLEAN NOT NULL DEFAULT FALSE,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now'))
+);
@@ -0,0 +1,6 @@
+CREATE TABLE group_members (
+    group_id TEXT REFERENCES groups(id) ON DELETE CASCADE,
+    user_id TEXT REFERENCES users(id) ON DELETE CASCADE,
+    joined_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    PRIMARY KEY (group_id, user_id)
+);
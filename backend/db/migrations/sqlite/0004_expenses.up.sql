@@ -0,0 +1,20 @@
+CREATE TABLE expenses (
+    id TEXT PRIMARY KEY,
+    group_id TEXT REFERENCES groups(id) ON DELETE CASCADE,
+    paid_by TEXT REFERENCES users(id) NOT NULL,
+    amount TEXT NOT NULL,
+    currency CHAR(3) NOT NULL DEFAULT 'INR',
+    -- units of the group's base currency per unit of currency
+    exchange_rate TEXT NOT NULL DEFAULT '1' CHECK (CAST(exchange_rate AS REAL) > 0),
+    -- amount converted into the group's base currency
+    base_amount TEXT NOT NULL,
+    split_type TEXT NOT NULL CHECK (split_type IN ('EQUAL', 'EXACT', 'PERCENT', 'SHARES', 'ADJUSTMENT', 'ITEMIZED')),
+    -- receipt tax and tip of an ITEMIZED expense, included in amount
+    tax TEXT NOT NULL DEFAULT '0' CHECK (CAST(tax AS REAL) >= 0),
+    tip TEXT NOT NULL DEFAULT '0' CHECK (CAST(tip AS REAL) >= 0),
+    description TEXT,
+    -- fingerprint of the creating request, used to recognise retries
+    request_hash TEXT,
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    updated_at TIMESTAMP
+);
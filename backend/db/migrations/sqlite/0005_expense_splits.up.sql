@@ -0,0 +1,11 @@
+CREATE TABLE expense_splits (
+    expense_id TEXT REFERENCES expenses(id) ON DELETE CASCADE,
+    user_id TEXT REFERENCES users(id),
+    amount TEXT,
+    base_amount TEXT,
+    percentage NUMERIC(5, 2),
+    CHECK (
+        amount IS NOT NULL OR percentage IS NOT NULL
+    ),
+    PRIMARY KEY (expense_id, user_id)
+);
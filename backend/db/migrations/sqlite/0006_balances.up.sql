@@ -0,0 +1,17 @@
+-- Balances are scoped to a group; group_id IS NULL is the direct
+-- (non-group) scope.
+CREATE TABLE balances (
+    group_id TEXT REFERENCES groups(id) ON DELETE CASCADE,
+    from_user_id TEXT REFERENCES users(id) NOT NULL,
+    to_user_id TEXT REFERENCES users(id) NOT NULL,
+    amount TEXT NOT NULL CHECK (CAST(amount AS REAL) >= 0),
+    -- the base currency of the group (or INR for the direct scope)
+    currency CHAR(3) NOT NULL DEFAULT 'INR',
+    CHECK (from_user_id <> to_user_id)
+);
+
+-- one row per pair in every scope, the direct scope included
+CREATE UNIQUE INDEX balances_scope_pair_idx
+    ON balances (COALESCE(group_id, ''), from_user_id, to_user_id);
+CREATE INDEX balances_from_user_idx ON balances (from_user_id);
+CREATE INDEX balances_to_user_idx ON balances (to_user_id);
@@ -0,0 +1,10 @@
+CREATE TABLE settlements (
+    id TEXT PRIMARY KEY,
+    group_id TEXT REFERENCES groups(id) ON DELETE CASCADE,
+    from_user_id TEXT REFERENCES users(id) NOT NULL,
+    to_user_id TEXT REFERENCES users(id) NOT NULL,
+    amount TEXT NOT NULL CHECK (CAST(amount AS REAL) > 0),
+    currency CHAR(3) NOT NULL DEFAULT 'INR',
+    created_at TIMESTAMP NOT NULL DEFAULT (strftime('%Y-%m-%d %H:%M:%f', 'now')),
+    CHECK (from_user_id <> to_user_id)
+);
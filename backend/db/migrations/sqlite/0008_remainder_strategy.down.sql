@@ -0,0 +1,2 @@
+-- Nothing to do: SQLite support came after this change, and
+-- 0002_groups creates groups with remainder_strategy.
@@ -0,0 +1,2 @@
+-- Nothing to do: SQLite support came after this change, and
+-- 0006_balances and 0007_settlements create them scoped by group.
@@ -0,0 +1,2 @@
+-- Nothing to do: SQLite support came after this change, and
+-- the tables are created with their currency columns.
@@ -0,0 +1,7 @@
+CREATE TABLE expense_payers (
+    expense_id TEXT REFERENCES expenses(id) ON DELETE CASCADE,
+    user_id TEXT REFERENCES users(id),
+    amount TEXT NOT NULL CHECK (CAST(amount AS REAL) > 0),
+    base_amount TEXT NOT NULL,
+    PRIMARY KEY (expense_id, user_id)
+);
@@ -0,0 +1,2 @@
+DROP TABLE expense_item_consumers;
+DROP TABLE expense_items;
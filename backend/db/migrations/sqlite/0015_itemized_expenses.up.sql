@@ -0,0 +1,17 @@
+CREATE TABLE expense_items (
+    expense_id TEXT REFERENCES expenses(id) ON DELETE CASCADE,
+    -- order of the item on the receipt
+    position INT NOT NULL,
+    name TEXT NOT NULL,
+    amount TEXT NOT NULL CHECK (CAST(amount AS REAL) > 0),
+    PRIMARY KEY (expense_id, position)
+);
+
+CREATE TABLE expense_item_consumers (
+    expense_id TEXT NOT NULL,
+    position INT NOT NULL,
+    user_id TEXT REFERENCES users(id),
+    FOREIGN KEY (expense_id, position)
+        REFERENCES expense_items(expense_id, position) ON DELETE CASCADE,
+    PRIMARY KEY (expense_id, position, user_id)
+);
@@ -0,0 +1,2 @@
+-- Nothing to do: SQLite support came after this change, and
+-- 0004_expenses creates expenses with request_hash.
@@ -0,0 +1,2 @@
+ALTER TABLE groups ADD COLUMN balances_simplified BOOLEAN NOT NULL DEFAULT FALSE;
+UPDATE groups SET balances_simplified = simplify_debts;
@@ -0,0 +1 @@
+-- Nothing to do: SQLite's strftime('now') defaults are already UTC.
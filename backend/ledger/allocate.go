@@ -0,0 +1,123 @@
+package ledger
+
+import (
+	"math/big"
+	"sort"
+)
+
+// RemainderStrategy decides who receives the minor units left over when
+// an amount cannot be divided exactly (100.00 split three ways leaves one
+// paisa). It is configured per group.
+type RemainderStrategy string
+
+const (
+	// RemainderLargest hands leftover units to the participants whose exact
+	// share lost the most to truncation, ties broken by user ID.
+	RemainderLargest RemainderStrategy = "LARGEST_REMAINDER"
+
+	// RemainderPayerFirst gives every leftover unit to the payer when they
+	// take part in the expense, and falls back to RemainderLargest otherwise.
+	RemainderPayerFirst RemainderStrategy = "PAYER_FIRST"
+)
+
+// DefaultRemainderStrategy applies when a group has not chosen one.
+const DefaultRemainderStrategy = RemainderLargest
+
+func (s RemainderStrategy) valid() bool {
+	return s == RemainderLargest || s == RemainderPayerFirst
+}
+
+// weight is one participant's claim on an amount being allocated.
+type weight struct {
+	userID string
+	weight int64
+}
+
+// allocate divides total across the weights in proportion to each weight,
+// so that the parts always sum to exactly total. Each participant first gets
+// floor(total * w / W); the remaining units are handed out one at a time
+// according to strategy.
+func allocate(
+	total Money,
+	weights []weight,
+	strategy RemainderStrategy,
+	payer string,
+) (map[string]Money, error) {
+
+	if len(weights) == 0 {
+		return nil, NewValidationError("splits", "no participants provided")
+	}
+
+	sumWeights := new(big.Int)
+	for _, w := range weights {
+		if w.weight <= 0 {
+			return nil, invalidf("splits", "weight for user %s must be positive", w.userID)
+		}
+		sumWeights.Add(sumWeights, big.NewInt(w.weight))
+	}
+
+	type part struct {
+		userID    string
+		minor     int64
+		remainder *big.Int
+	}
+
+	parts := make([]part, 0, len(weights))
+	allocated := int64(0)
+	for _, w := range weights {
+		product := new(big.Int).Mul(big.NewInt(total.Minor), big.NewInt(w.weight))
+		quotient, remainder := new(big.Int).QuoRem(product, sumWeights, new(big.Int))
+		parts = append(parts, part{
+			userID:    w.userID,
+			minor:     quotient.Int64(),
+			remainder: remainder,
+		})
+		allocated += quotient.Int64()
+	}
+
+	leftover := total.Minor - allocated
+
+	payerIndex := -1
+	if strategy == RemainderPayerFirst {
+		for i, p := range parts {
+			if p.userID == payer {
+				payerIndex = i
+				break
+			}
+		}
+	}
+
+	if payerIndex >= 0 {
+		parts[payerIndex].minor += leftover
+	} else {
+		order := make([]int, len(parts))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			pa, pb := parts[order[a]], parts[order[b]]
+			if c := pa.remainder.Cmp(pb.remainder); c != 0 {
+				return c > 0
+			}
+			return pa.userID < pb.userID
+		})
+		for i := int64(0); i < leftover; i++ {
+			parts[order[i]].minor++
+		}
+	}
+
+	shares := make(map[string]Money, len(parts))
+	for _, p := range parts {
+		shares[p.userID] = shares[p.userID].Add(NewMoney(p.minor, total.Currency))
+	}
+	return shares, nil
+}
+
+// sumShares adds up every share in the map.
+func sumShares(shares map[string]Money, currency string) Money {
+	total := NewMoney(0, currency)
+	for _, amount := range shares {
+		total = total.Add(amount)
+	}
+	return total
+}
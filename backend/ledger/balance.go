@@ -1,84 +1,77 @@
 package ledger
 
 import (
-	"database/sql"
+	"context"
 	"errors"
 )
 
+// DirectScope is the balance scope for expenses and settlements that do not
+// belong to any group. Every other scope is a group ID.
+const DirectScope = ""
+
+// applyBalanceDelta records that fromUserID owes toUserID amount more within
+// the given group scope, netting against any reverse balance in that scope.
+// amount must be in the scope's base currency.
 func applyBalanceDelta(
-	tx *sql.Tx,
+	ctx context.Context,
+	tx storeTx,
+	groupID string,
 	fromUserID string,
 	toUserID string,
-	amount float64,
+	amount Money,
 ) error {
 
 	// Guard conditions
 	if fromUserID == toUserID {
 		return nil
 	}
-	if amount <= 0 {
+	if !amount.IsPositive() {
 		return errors.New("amount must be positive")
 	}
 
-	var existing float64
-
 	// 1. Check for reverse balance (to -> from)
-	err := tx.QueryRow(`
-		SELECT amount
-		FROM balances
-		WHERE from_user_id = $1 AND to_user_id = $2
-	`, toUserID, fromUserID).Scan(&existing)
+	existing, ok, err := tx.balance(ctx, groupID, toUserID, fromUserID)
+	if err != nil {
+		return err
+	}
 
-	if err == nil {
+	if ok {
 		// Reverse balance exists → net it
 		switch {
-		case existing > amount:
+		case existing.Cmp(amount) > 0:
 			// Reduce reverse balance
-			_, err = tx.Exec(`
-				UPDATE balances
-				SET amount = amount - $1
-				WHERE from_user_id = $2 AND to_user_id = $3
-			`, amount, toUserID, fromUserID)
-			return err
+			return tx.putBalance(ctx, balanceRow(groupID, toUserID, fromUserID, existing.Sub(amount)))
 
-		case existing < amount:
+		case existing.Cmp(amount) < 0:
 			// Remove reverse balance
-			_, err = tx.Exec(`
-				DELETE FROM balances
-				WHERE from_user_id = $1 AND to_user_id = $2
-			`, toUserID, fromUserID)
-			if err != nil {
+			if err := tx.deleteBalance(ctx, groupID, toUserID, fromUserID); err != nil {
 				return err
 			}
 
 			// Insert remaining forward balance
-			_, err = tx.Exec(`
-				INSERT INTO balances (from_user_id, to_user_id, amount)
-				VALUES ($1, $2, $3)
-			`, fromUserID, toUserID, amount-existing)
-			return err
+			return tx.putBalance(ctx, balanceRow(groupID, fromUserID, toUserID, amount.Sub(existing)))
 
 		default:
 			// existing == amount → cancel out
-			_, err = tx.Exec(`
-				DELETE FROM balances
-				WHERE from_user_id = $1 AND to_user_id = $2
-			`, toUserID, fromUserID)
-			return err
+			return tx.deleteBalance(ctx, groupID, toUserID, fromUserID)
 		}
 	}
 
 	// If no reverse balance exists, add or increment forward balance
-	if err != sql.ErrNoRows {
+	forward, _, err := tx.balance(ctx, groupID, fromUserID, toUserID)
+	if err != nil {
 		return err
 	}
+	return tx.putBalance(ctx, balanceRow(groupID, fromUserID, toUserID, forward.Add(amount)))
+}
 
-	_, err = tx.Exec(`
-		INSERT INTO balances (from_user_id, to_user_id, amount)
-		VALUES ($1, $2, $3)
-		ON CONFLICT (from_user_id, to_user_id)
-		DO UPDATE SET amount = balances.amount + EXCLUDED.amount
-	`, fromUserID, toUserID, amount)
-
-	return err
+// balanceRow builds the balance record for fromUserID owing toUserID amount.
+func balanceRow(groupID, fromUserID, toUserID string, amount Money) BalanceView {
+	return BalanceView{
+		GroupID:    groupID,
+		FromUserID: fromUserID,
+		ToUserID:   toUserID,
+		Amount:     amount,
+		Currency:   amount.Currency,
+	}
 }
@@ -0,0 +1,161 @@
+package ledger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+)
+
+// rateDecimals is the precision exchange rates are stored with
+// (expenses.exchange_rate is NUMERIC(20, 10)).
+const rateDecimals = 10
+
+// maxRate bounds exchange rates to what NUMERIC(20, 10) can hold: ten
+// digits before the decimal point.
+var maxRate = big.NewRat(1e10, 1)
+
+// RateProvider supplies the exchange rates used to convert an expense into
+// its group's base currency.
+type RateProvider interface {
+	// Rate returns how many units of `to` one unit of `from` is worth.
+	Rate(ctx context.Context, from string, to string) (*big.Rat, error)
+}
+
+// StaticRates is a RateProvider backed by a fixed table of rates against a
+// single base currency. It needs no network access and suits offline use.
+type StaticRates struct {
+	base  string
+	rates map[string]*big.Rat // units of base per one unit of the currency
+}
+
+// NewStaticRates builds a rate table from decimal strings. Each entry says
+// how many units of base one unit of that currency is worth, e.g.
+// NewStaticRates("INR", map[string]string{"USD": "83.25"}).
+func NewStaticRates(base string, rates map[string]string) (*StaticRates, error) {
+	base = normalizeCurrency(base)
+	if !validCurrency(base) {
+		return nil, fmt.Errorf("invalid base currency %q", base)
+	}
+
+	s := &StaticRates{
+		base:  base,
+		rates: map[string]*big.Rat{base: big.NewRat(1, 1)},
+	}
+	for currency, value := range rates {
+		currency = normalizeCurrency(currency)
+		if !validCurrency(currency) {
+			return nil, fmt.Errorf("invalid currency %q", currency)
+		}
+		rate, err := parseRate(value)
+		if err != nil {
+			return nil, fmt.Errorf("rate for %s: %w", currency, err)
+		}
+		s.rates[currency] = rate
+	}
+	return s, nil
+}
+
+// LoadRatesFile reads a StaticRates table from a JSON file of the form
+//
+//	{"base": "INR", "rates": {"USD": "83.25", "EUR": "90.10"}}
+func LoadRatesFile(path string) (*StaticRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Base  string            `json:"base"`
+		Rates map[string]string `json:"rates"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return NewStaticRates(file.Base, file.Rates)
+}
+
+// Rate implements RateProvider, crossing through the base currency when
+// neither side is the base.
+func (s *StaticRates) Rate(_ context.Context, from string, to string) (*big.Rat, error) {
+	from, to = normalizeCurrency(from), normalizeCurrency(to)
+	if from == to {
+		return big.NewRat(1, 1), nil
+	}
+
+	fromRate, ok := s.rates[from]
+	if !ok {
+		return nil, fmt.Errorf("no exchange rate for %s", from)
+	}
+	toRate, ok := s.rates[to]
+	if !ok {
+		return nil, fmt.Errorf("no exchange rate for %s", to)
+	}
+	return new(big.Rat).Quo(fromRate, toRate), nil
+}
+
+// parseRate parses a positive decimal exchange rate.
+func parseRate(value string) (*big.Rat, error) {
+	rate, ok := new(big.Rat).SetString(strings.TrimSpace(value))
+	if !ok {
+		return nil, fmt.Errorf("invalid exchange rate %q", value)
+	}
+	if rate.Sign() <= 0 {
+		return nil, errors.New("exchange rate must be positive")
+	}
+	return rate, nil
+}
+
+// roundRate rounds a rate to the precision it is stored with, so the rate
+// recorded on an expense reproduces the conversion exactly.
+func roundRate(rate *big.Rat) (*big.Rat, error) {
+	rounded, ok := new(big.Rat).SetString(rate.FloatString(rateDecimals))
+	if !ok || rounded.Sign() <= 0 {
+		return nil, errors.New("exchange rate is too small")
+	}
+	if rounded.Cmp(maxRate) >= 0 {
+		return nil, errors.New("exchange rate is too large")
+	}
+	return rounded, nil
+}
+
+// convert turns m into the target currency at the given rate, rounding half
+// away from zero to the nearest minor unit. It fails when the result does
+// not fit in an int64 of minor units.
+func convert(m Money, rate *big.Rat, to string) (Money, error) {
+	r := new(big.Rat).Mul(new(big.Rat).SetInt64(m.Minor), rate)
+	num, den := r.Num(), r.Denom()
+
+	quo, rem := new(big.Int).QuoRem(num, den, new(big.Int))
+	if new(big.Int).Mul(new(big.Int).Abs(rem), big.NewInt(2)).Cmp(den) >= 0 {
+		if num.Sign() < 0 {
+			quo.Sub(quo, big.NewInt(1))
+		} else {
+			quo.Add(quo, big.NewInt(1))
+		}
+	}
+	if !quo.IsInt64() {
+		return Money{}, errors.New("amount is too large after currency conversion")
+	}
+	return NewMoney(quo.Int64(), to), nil
+}
+
+func normalizeCurrency(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// validCurrency reports whether code looks like an ISO 4217 code.
+func validCurrency(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,104 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+func TestStaticRates_CrossRate(t *testing.T) {
+	rates, err := NewStaticRates("INR", map[string]string{
+		"USD": "80",
+		"EUR": "90",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	rate, err := rates.Rate(context.Background(), "EUR", "USD")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if rate.Cmp(big.NewRat(9, 8)) != 0 {
+		t.Errorf("EUR->USD = %s, want 9/8", rate.RatString())
+	}
+
+	if _, err := rates.Rate(context.Background(), "GBP", "INR"); err == nil {
+		t.Errorf("expected error for unknown currency")
+	}
+}
+
+func TestConvert_RoundsHalfAwayFromZero(t *testing.T) {
+	rate := big.NewRat(1, 2)
+
+	if got, _ := convert(NewMoney(5, "USD"), rate, "INR"); got != inr(3) {
+		t.Errorf("convert(0.05 * 0.5) = %v, want 0.03", got)
+	}
+	if got, _ := convert(NewMoney(-5, "USD"), rate, "INR"); got != inr(-3) {
+		t.Errorf("convert(-0.05 * 0.5) = %v, want -0.03", got)
+	}
+}
+
+func TestConvert_RejectsOverflow(t *testing.T) {
+	if _, err := convert(NewMoney(20000000000000, "USD"), big.NewRat(1000000, 1), "INR"); err == nil {
+		t.Error("convert() of a total beyond int64 succeeded")
+	}
+}
+
+func TestCreateExpense_RejectsOutOfRangeConversions(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLedger(t)
+
+	tests := []struct {
+		name  string
+		total int64
+		rate  string
+		field string
+	}{
+		{"converted total wraps", 20000000000000, "1000000", "total_amount"},
+		{"converted total wraps negative", 10000000000000, "1000000", "total_amount"},
+		{"rate beyond NUMERIC(20, 10)", 100, "10000000000", "exchange_rate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			input := equalExpense("e1", "g1", "u1", tt.total, "u1", "u2")
+			input.Currency = "USD"
+			input.ExchangeRate = tt.rate
+
+			err := l.CreateExpense(ctx, input)
+			var ledgerErr *Error
+			if !errors.As(err, &ledgerErr) || len(ledgerErr.Fields) != 1 || ledgerErr.Fields[0].Field != tt.field {
+				t.Fatalf("CreateExpense() error = %v, want a validation error on %s", err, tt.field)
+			}
+		})
+	}
+
+	input := equalExpense("e1", "g1", "u1", 100, "u1", "u2")
+	input.Currency = "USD"
+	input.ExchangeRate = "9999999999.9999999999"
+	if err := l.CreateExpense(ctx, input); err != nil {
+		t.Errorf("CreateExpense() at the largest storable rate: %v", err)
+	}
+}
+
+func TestConvertShares_SumsToConvertedTotal(t *testing.T) {
+	shares := map[string]Money{
+		"u1": NewMoney(3334, "USD"),
+		"u2": NewMoney(3333, "USD"),
+		"u3": NewMoney(3333, "USD"),
+	}
+	baseTotal, err := convert(NewMoney(10000, "USD"), big.NewRat(8325, 100), DefaultCurrency)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	converted, err := convertShares(shares, baseTotal, RemainderLargest, "u1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if total := sumShares(converted, DefaultCurrency); total != baseTotal {
+		t.Errorf("converted shares sum to %v, want %v: %v", total, baseTotal, converted)
+	}
+}
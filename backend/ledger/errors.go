@@ -0,0 +1,83 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error kinds. Every failure the caller can act on matches exactly one of
+// them with errors.Is; anything else is an internal error such as a lost
+// database connection.
+var (
+	// ErrValidation means the request itself is wrong and retrying it
+	// unchanged fails the same way.
+	ErrValidation = errors.New("validation failed")
+	// ErrNotFound means a record the request names does not exist.
+	ErrNotFound = errors.New("not found")
+	// ErrConflict means the request clashes with the ledger's current
+	// state, e.g. a reused ID or a user who still has balances.
+	ErrConflict = errors.New("conflict")
+	// ErrInsufficientBalance means a settlement asks for more than is
+	// owed.
+	ErrInsufficientBalance = errors.New("insufficient balance")
+	// ErrSerialization means the transaction kept colliding with
+	// concurrent writes; the same request can be retried later.
+	ErrSerialization = errors.New("serialization failure")
+)
+
+// Error is a failure of one of the kinds above. Code identifies it in a
+// stable, machine-readable way, and Fields point at the input at fault.
+type Error struct {
+	Kind    error
+	Code    string
+	Message string
+	Fields  []FieldError
+}
+
+// FieldError describes what is wrong with one input field. UserIDs lists
+// the offending users when the field names users.
+type FieldError struct {
+	Field   string   `json:"field"`
+	Message string   `json:"message"`
+	UserIDs []string `json:"user_ids,omitempty"`
+}
+
+func (e *Error) Error() string { return e.Message }
+
+// Is matches the error's kind.
+func (e *Error) Is(target error) bool { return target == e.Kind }
+
+// NewValidationError returns an ErrValidation error about one field.
+func NewValidationError(field, message string) *Error {
+	return &Error{
+		Kind:    ErrValidation,
+		Code:    "validation_failed",
+		Message: message,
+		Fields:  []FieldError{{Field: field, Message: message}},
+	}
+}
+
+// invalidf is NewValidationError with a formatted message.
+func invalidf(field, format string, args ...any) *Error {
+	return NewValidationError(field, fmt.Sprintf(format, args...))
+}
+
+// checkIDs returns a validation error for the first non-empty ID, given
+// as field and value pairs, that the store could never hold.
+func checkIDs(s storeReader, fieldsAndIDs ...string) error {
+	for i := 0; i+1 < len(fieldsAndIDs); i += 2 {
+		field, id := fieldsAndIDs[i], fieldsAndIDs[i+1]
+		if id != "" && !s.validID(id) {
+			return invalidf(field, "%s is not a valid ID", field)
+		}
+	}
+	return nil
+}
+
+func notFound(code, message string) *Error {
+	return &Error{Kind: ErrNotFound, Code: code, Message: message}
+}
+
+func conflict(code, message string) *Error {
+	return &Error{Kind: ErrConflict, Code: code, Message: message}
+}
@@ -0,0 +1,84 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+var errorKinds = []error{ErrValidation, ErrNotFound, ErrConflict, ErrInsufficientBalance, ErrSerialization}
+
+// assertKind checks that err matches kind and no other kind.
+func assertKind(t *testing.T, err, kind error) {
+	t.Helper()
+	for _, k := range errorKinds {
+		if got, want := errors.Is(err, k), k == kind; got != want {
+			t.Errorf("errors.Is(%v, %v) = %v, want %v", err, k, got, want)
+		}
+	}
+}
+
+func TestSentinelErrors_HaveOneKind(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind error
+	}{
+		{ErrExpenseNotFound, ErrNotFound},
+		{ErrUserNotFound, ErrNotFound},
+		{ErrGroupNotFound, ErrNotFound},
+		{ErrNotGroupMember, ErrNotFound},
+		{ErrExpenseConflict, ErrConflict},
+		{ErrSettlementConflict, ErrConflict},
+		{ErrEmailTaken, ErrConflict},
+		{ErrUserHasBalances, ErrConflict},
+		{ErrGroupArchived, ErrConflict},
+		{ErrGroupHasBalances, ErrConflict},
+		{ErrMemberHasBalances, ErrConflict},
+		{ErrNoOutstandingBalance, ErrInsufficientBalance},
+		{ErrSettlementExceedsBalance, ErrInsufficientBalance},
+		{&TxConflictError{Attempts: maxTxAttempts, Err: errors.New("40001")}, ErrSerialization},
+	}
+	for _, tt := range tests {
+		assertKind(t, tt.err, tt.kind)
+	}
+}
+
+func TestCreateExpense_ValidationErrorNamesField(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLedger(t)
+
+	input := equalExpense("e1", "g1", "u1", 0, "u1", "u2")
+	err := l.CreateExpense(ctx, input)
+	assertKind(t, err, ErrValidation)
+
+	var ledgerErr *Error
+	if !errors.As(err, &ledgerErr) || len(ledgerErr.Fields) != 1 || ledgerErr.Fields[0].Field != "total_amount" {
+		t.Errorf("CreateExpense() error = %#v, want a validation error on total_amount", err)
+	}
+}
+
+func TestMembershipError_ListsUsersAsFieldDetail(t *testing.T) {
+	err := error(&MembershipError{GroupID: "g1", UserIDs: []string{"u4", "u9"}})
+	assertKind(t, err, ErrValidation)
+
+	var ledgerErr *Error
+	if !errors.As(err, &ledgerErr) {
+		t.Fatalf("%v does not unwrap to an *Error", err)
+	}
+	if ledgerErr.Code != "not_group_members" || len(ledgerErr.Fields) != 1 ||
+		!reflect.DeepEqual(ledgerErr.Fields[0].UserIDs, []string{"u4", "u9"}) {
+		t.Errorf("unexpected error: %#v", ledgerErr)
+	}
+}
+
+func TestSettleBalance_InsufficientBalance(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLedger(t)
+
+	_, err := l.SettleBalance(ctx, "g1", "u2", "u1", inr(100))
+	if !errors.Is(err, ErrNoOutstandingBalance) {
+		t.Fatalf("SettleBalance() error = %v, want %v", err, ErrNoOutstandingBalance)
+	}
+	assertKind(t, err, ErrInsufficientBalance)
+}
@@ -2,69 +2,549 @@ package ledger
 
 import (
 	"context"
-	"database/sql"
 	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
 )
 
+// ErrExpenseNotFound is returned when an expense ID does not exist.
+var ErrExpenseNotFound error = notFound("expense_not_found", "expense not found")
+
+// MembershipError is returned for an expense naming users who cannot take
+// part in it: for a group expense, users who are not active members of the
+// group; for a direct expense, users who do not exist or are deactivated.
+type MembershipError struct {
+	GroupID string   // empty for a direct expense
+	UserIDs []string // sorted
+}
+
+func (e *MembershipError) Error() string {
+	if e.GroupID == DirectScope {
+		return fmt.Sprintf("unknown or deactivated users: %s", strings.Join(e.UserIDs, ", "))
+	}
+	return fmt.Sprintf("users are not members of group %s: %s", e.GroupID, strings.Join(e.UserIDs, ", "))
+}
+
+// Unwrap presents the error as an ErrValidation *Error listing the users.
+func (e *MembershipError) Unwrap() error {
+	code, message := "not_group_members", "not members of the group"
+	if e.GroupID == DirectScope {
+		code, message = "unknown_users", "unknown or deactivated users"
+	}
+	return &Error{
+		Kind:    ErrValidation,
+		Code:    code,
+		Message: e.Error(),
+		Fields:  []FieldError{{Field: "participants", Message: message, UserIDs: e.UserIDs}},
+	}
+}
+
+// preparedExpense is a validated expense with every amount worked out,
+// ready to be written.
+type preparedExpense struct {
+	input      ExpenseInput
+	rate       *big.Rat
+	baseTotal  Money
+	payers     map[string]Money // in the expense currency
+	basePayers map[string]Money // in the group's base currency
+	shares     map[string]Money // in the expense currency
+	baseShares map[string]Money // in the group's base currency
+}
+
+// CreateExpense records a new expense and updates balances. The client
+// chooses the expense ID, which makes the call idempotent: repeating the
+// same request is a no-op, while reusing the ID for a different expense
+// fails with ErrExpenseConflict.
 func (l *Ledger) CreateExpense(ctx context.Context, input ExpenseInput) error {
-	return l.withTx(func(tx *sql.Tx) error {
+	if input.ExpenseID == "" {
+		return NewValidationError("expense_id", "expense_id must be provided")
+	}
+	hash, err := expenseRequestHash(input)
+	if err != nil {
+		return err
+	}
 
-		if input.TotalAmount <= 0 {
-			return errors.New("total amount must be greater than 0")
+	return l.withTx(ctx, func(tx storeTx) error {
+
+		// a retried request finds its expense already recorded; concurrent
+		// retries are serialized by the transaction isolation level
+		existing, ok, err := tx.expenseRequestHash(ctx, input.ExpenseID)
+		if err != nil {
+			return err
 		}
-		if input.PaidBy == "" {
-			return errors.New("paidBy must be provided")
-		}
-		if len(input.Participants) == 0 {
-			return errors.New("at least one participant is required")
-		}
-
-		// insert expense
-		_, err := tx.Exec(
-			`INSERT INTO expenses (id, group_id, paid_by, amount, split_type, description)
-			 VALUES ($1, $2, $3, $4, $5, $6)`,
-			input.ExpenseID,
-			input.GroupID,
-			input.PaidBy,
-			input.TotalAmount,
-			input.SplitType,
-			input.Description,
-		)
+		if ok {
+			if existing != hash {
+				return ErrExpenseConflict
+			}
+			return nil
+		}
+
+		p, err := l.prepareExpense(ctx, tx, input)
 		if err != nil {
 			return err
 		}
 
-		// calculate shares
-		shares, err := calculateShares(input)
+		// insert expense with its payers, splits and items
+		if err := tx.insertExpense(ctx, p.view(), hash); err != nil {
+			return err
+		}
+		if err := applyExpenseBalances(ctx, tx, p); err != nil {
+			return err
+		}
+		return simplifyIfEnabled(ctx, tx, p.input.GroupID)
+	})
+}
+
+// UpdateExpense replaces an expense's amount, payer, participants and
+// splits. The old splits' effect on balances is reversed and the new one
+// applied in the same transaction, so balances never see a half-edited
+// expense. The expense keeps its ID and creation time. Replaying the
+// request that created it then fails with ErrExpenseConflict, since the
+// expense no longer matches it.
+func (l *Ledger) UpdateExpense(
+	ctx context.Context,
+	expenseID string,
+	input ExpenseInput,
+) error {
+
+	input.ExpenseID = expenseID
+	hash, err := expenseRequestHash(input)
+	if err != nil {
+		return err
+	}
+
+	return l.withTx(ctx, func(tx storeTx) error {
+
+		p, err := l.prepareExpense(ctx, tx, input)
 		if err != nil {
 			return err
 		}
 
-		// insert expense_splits
-		for userID, amount := range shares {
-			_, err := tx.Exec(
-				`INSERT INTO expense_splits (expense_id, user_id, amount)
-				 VALUES ($1, $2, $3)`,
-				input.ExpenseID,
-				userID,
-				amount,
-			)
-			if err != nil {
-				return err
-			}
+		oldGroupID, err := reverseExpenseBalances(ctx, tx, expenseID)
+		if err != nil {
+			return err
 		}
 
-		// update balances
-		for userID, amount := range shares {
-			if userID == input.PaidBy {
-				continue
-			}
-			err := applyBalanceDelta(tx, userID, input.PaidBy, amount)
-			if err != nil {
+		if err := tx.updateExpense(ctx, p.view(), hash); err != nil {
+			return err
+		}
+		if err := applyExpenseBalances(ctx, tx, p); err != nil {
+			return err
+		}
+
+		// the expense may have moved between groups
+		if oldGroupID != p.input.GroupID {
+			if err := simplifyIfEnabled(ctx, tx, oldGroupID); err != nil {
 				return err
 			}
 		}
+		return simplifyIfEnabled(ctx, tx, p.input.GroupID)
+	})
+}
 
-		return nil
+// DeleteExpense removes an expense and its splits, reversing its effect on
+// balances.
+func (l *Ledger) DeleteExpense(ctx context.Context, expenseID string) error {
+	return l.withTx(ctx, func(tx storeTx) error {
+
+		groupID, err := reverseExpenseBalances(ctx, tx, expenseID)
+		if err != nil {
+			return err
+		}
+
+		if err := tx.deleteExpense(ctx, expenseID); err != nil {
+			return err
+		}
+		return simplifyIfEnabled(ctx, tx, groupID)
 	})
 }
+
+// prepareExpense validates the input and works out the exchange rate and
+// every participant's share, without writing anything.
+func (l *Ledger) prepareExpense(
+	ctx context.Context,
+	tx storeTx,
+	input ExpenseInput,
+) (*preparedExpense, error) {
+
+	if input.ExpenseID == "" {
+		return nil, NewValidationError("expense_id", "expense_id must be provided")
+	}
+	if !tx.validID(input.ExpenseID) {
+		return nil, NewValidationError("expense_id", "expense_id is not a valid ID")
+	}
+	if !input.TotalAmount.IsPositive() {
+		return nil, NewValidationError("total_amount", "total amount must be greater than 0")
+	}
+	if len(input.Participants) == 0 {
+		return nil, NewValidationError("participants", "at least one participant is required")
+	}
+	if input.GroupID == DirectScope && !input.Direct {
+		return nil, NewValidationError("group_id", "group_id must be provided, or direct set for a non-group expense")
+	}
+	if input.GroupID != DirectScope && input.Direct {
+		return nil, NewValidationError("direct", "a direct expense cannot have a group_id")
+	}
+
+	settings, err := tx.groupSettings(ctx, input.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	if settings.archived {
+		return nil, ErrGroupArchived
+	}
+	if err := checkExpenseUsers(ctx, tx, input); err != nil {
+		return nil, err
+	}
+
+	// resolve currency and exchange rate into the group's base currency
+	input.Currency = normalizeCurrency(input.Currency)
+	if input.Currency == "" {
+		input.Currency = settings.baseCurrency
+	}
+	if !validCurrency(input.Currency) {
+		return nil, invalidf("currency", "invalid currency %q", input.Currency)
+	}
+	input.TotalAmount.Currency = input.Currency
+	input.Tax.Currency = input.Currency
+	input.Tip.Currency = input.Currency
+
+	if input.SplitType != SplitItemized &&
+		(len(input.Items) > 0 || !input.Tax.IsZero() || !input.Tip.IsZero()) {
+		return nil, NewValidationError("items", "items, tax and tip are only allowed for ITEMIZED splits")
+	}
+
+	payers, err := normalizePayers(&input)
+	if err != nil {
+		return nil, err
+	}
+
+	rate, err := l.exchangeRate(ctx, input, settings.baseCurrency)
+	if err != nil {
+		return nil, err
+	}
+	baseTotal, err := convert(input.TotalAmount, rate, settings.baseCurrency)
+	if err != nil {
+		return nil, NewValidationError("total_amount", err.Error())
+	}
+	if !baseTotal.IsPositive() {
+		return nil, NewValidationError("total_amount", "total amount is zero after currency conversion")
+	}
+
+	// calculate shares
+	shares, err := calculateShares(input, settings.remainderStrategy)
+	if err != nil {
+		return nil, err
+	}
+	if sumShares(shares, input.Currency).Cmp(input.TotalAmount) != 0 {
+		return nil, NewValidationError("splits", "splits do not add up to the total amount")
+	}
+
+	baseShares, err := convertShares(shares, baseTotal, settings.remainderStrategy, input.PaidBy)
+	if err != nil {
+		return nil, err
+	}
+	basePayers, err := convertShares(payers, baseTotal, settings.remainderStrategy, input.PaidBy)
+	if err != nil {
+		return nil, err
+	}
+
+	return &preparedExpense{
+		input:      input,
+		rate:       rate,
+		baseTotal:  baseTotal,
+		payers:     payers,
+		basePayers: basePayers,
+		shares:     shares,
+		baseShares: baseShares,
+	}, nil
+}
+
+// checkExpenseUsers returns a *MembershipError naming every user in the
+// input who cannot take part in the expense.
+func checkExpenseUsers(ctx context.Context, tx storeTx, input ExpenseInput) error {
+	named := map[string]bool{input.PaidBy: true}
+	for _, p := range input.Payers {
+		named[p.UserID] = true
+	}
+	for _, userID := range input.Participants {
+		named[userID] = true
+	}
+	for _, s := range input.Splits {
+		named[s.UserID] = true
+	}
+	for _, item := range input.Items {
+		for _, userID := range item.Consumers {
+			named[userID] = true
+		}
+	}
+	// missing IDs are reported by the checks that need them
+	delete(named, "")
+
+	invalid := []string{}
+	for userID := range named {
+		ok, err := canTakePart(ctx, tx, input.GroupID, userID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			invalid = append(invalid, userID)
+		}
+	}
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		return &MembershipError{GroupID: input.GroupID, UserIDs: invalid}
+	}
+	return nil
+}
+
+// canTakePart reports whether the user is active and, outside the direct
+// scope, a member of the group.
+func canTakePart(ctx context.Context, tx storeTx, groupID, userID string) (bool, error) {
+	if _, err := tx.user(ctx, userID); err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	if groupID == DirectScope {
+		return true, nil
+	}
+	return tx.isGroupMember(ctx, groupID, userID)
+}
+
+// normalizePayers validates who paid for the expense and returns each
+// payer's amount. A single PaidBy is treated as one payer of the full
+// amount. When only Payers is given, the largest payer (ties broken by user
+// ID) becomes PaidBy, which is stored as the expense's primary payer.
+func normalizePayers(input *ExpenseInput) (map[string]Money, error) {
+	if len(input.Payers) == 0 {
+		if input.PaidBy == "" {
+			return nil, NewValidationError("paid_by", "paid_by must be provided")
+		}
+		input.Payers = []PayerInput{{UserID: input.PaidBy, Amount: input.TotalAmount}}
+		return map[string]Money{input.PaidBy: input.TotalAmount}, nil
+	}
+
+	payers := make(map[string]Money, len(input.Payers))
+	total := NewMoney(0, input.Currency)
+	primary := ""
+	for i, payer := range input.Payers {
+		if payer.UserID == "" {
+			return nil, NewValidationError("payers", "payer user_id must be provided")
+		}
+		if !payer.Amount.IsPositive() {
+			return nil, NewValidationError("payers", "payer amount must be positive")
+		}
+		if _, dup := payers[payer.UserID]; dup {
+			return nil, invalidf("payers", "payer %s is listed more than once", payer.UserID)
+		}
+
+		amount := NewMoney(payer.Amount.Minor, input.Currency)
+		input.Payers[i].Amount = amount
+		payers[payer.UserID] = amount
+		total = total.Add(amount)
+
+		if primary == "" ||
+			amount.Cmp(payers[primary]) > 0 ||
+			(amount.Cmp(payers[primary]) == 0 && payer.UserID < primary) {
+			primary = payer.UserID
+		}
+	}
+
+	if total.Cmp(input.TotalAmount) != 0 {
+		return nil, NewValidationError("payers", "sum of payer amounts must equal total amount")
+	}
+	if input.PaidBy == "" {
+		input.PaidBy = primary
+	}
+	if _, ok := payers[input.PaidBy]; !ok {
+		return nil, NewValidationError("paid_by", "paid_by must be one of the payers")
+	}
+
+	return payers, nil
+}
+
+// expenseTransfers works out who owes whom for one expense. Each user's net
+// position is what they paid minus what they owe; debtors are then matched
+// against payers with minimizeTransfers, so with a single payer every
+// participant simply owes the payer their share.
+func expenseTransfers(paid map[string]Money, owed map[string]Money) []transfer {
+	net := map[string]int64{}
+	for userID, amount := range paid {
+		net[userID] += amount.Minor
+	}
+	for userID, amount := range owed {
+		net[userID] -= amount.Minor
+	}
+	return minimizeTransfers(net)
+}
+
+// view builds the expense record to store, with its payers, splits and
+// receipt items in a stable order.
+func (p *preparedExpense) view() ExpenseView {
+	e := ExpenseView{
+		ID:           p.input.ExpenseID,
+		GroupID:      p.input.GroupID,
+		PaidBy:       p.input.PaidBy,
+		Amount:       p.input.TotalAmount,
+		Currency:     p.input.Currency,
+		ExchangeRate: p.rate.FloatString(rateDecimals),
+		BaseAmount:   p.baseTotal,
+		BaseCurrency: p.baseTotal.Currency,
+		SplitType:    p.input.SplitType,
+		Tax:          p.input.Tax,
+		Tip:          p.input.Tip,
+		Description:  p.input.Description,
+		Payers:       []ExpensePayerView{},
+		Splits:       []ExpenseSplitView{},
+	}
+
+	for _, userID := range sortedUsers(p.payers) {
+		e.Payers = append(e.Payers, ExpensePayerView{
+			UserID:     userID,
+			Amount:     p.payers[userID],
+			BaseAmount: p.basePayers[userID],
+		})
+	}
+	for _, userID := range sortedUsers(p.shares) {
+		e.Splits = append(e.Splits, ExpenseSplitView{
+			UserID:     userID,
+			Amount:     p.shares[userID],
+			BaseAmount: p.baseShares[userID],
+		})
+	}
+	for _, item := range p.input.Items {
+		e.Items = append(e.Items, ExpenseItemView{
+			Name:      item.Name,
+			Amount:    NewMoney(item.Price.Minor, p.input.Currency),
+			Consumers: item.Consumers,
+		})
+	}
+	return e
+}
+
+// sortedUsers returns the user IDs of an amounts map in order.
+func sortedUsers(amounts map[string]Money) []string {
+	users := make([]string, 0, len(amounts))
+	for userID := range amounts {
+		users = append(users, userID)
+	}
+	sort.Strings(users)
+	return users
+}
+
+// applyExpenseBalances records what every participant owes the payers, in
+// the group's base currency.
+func applyExpenseBalances(ctx context.Context, tx storeTx, p *preparedExpense) error {
+	for _, t := range expenseTransfers(p.basePayers, p.baseShares) {
+		amount := NewMoney(t.minor, p.baseTotal.Currency)
+		if err := applyBalanceDelta(ctx, tx, p.input.GroupID, t.from, t.to, amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reverseExpenseBalances undoes the balance changes made when the stored
+// expense was recorded, by applying each of its transfers in the opposite
+// direction. It returns the expense's group.
+func reverseExpenseBalances(ctx context.Context, tx storeTx, expenseID string) (string, error) {
+	e, err := tx.lockExpense(ctx, expenseID)
+	if err != nil {
+		return "", err
+	}
+	if err := checkGroupActive(ctx, tx, e.GroupID); err != nil {
+		return "", err
+	}
+
+	paid, owed := baseAmounts(e)
+	for _, t := range expenseTransfers(paid, owed) {
+		// the debtor owed the payer; now the payer owes it back
+		amount := NewMoney(t.minor, e.BaseCurrency)
+		if err := applyBalanceDelta(ctx, tx, e.GroupID, t.to, t.from, amount); err != nil {
+			return "", err
+		}
+	}
+	return e.GroupID, nil
+}
+
+// baseAmounts returns what each payer paid and each participant owes for a
+// stored expense, in the group's base currency.
+func baseAmounts(e ExpenseView) (paid map[string]Money, owed map[string]Money) {
+	paid = make(map[string]Money, len(e.Payers))
+	for _, p := range e.Payers {
+		paid[p.UserID] = p.BaseAmount
+	}
+	owed = make(map[string]Money, len(e.Splits))
+	for _, s := range e.Splits {
+		owed[s.UserID] = s.BaseAmount
+	}
+	return paid, owed
+}
+
+// exchangeRate returns the rate used to convert the expense into base. An
+// explicit rate on the input wins over the configured RateProvider. The rate
+// is rounded to the precision it is stored with.
+func (l *Ledger) exchangeRate(
+	ctx context.Context,
+	input ExpenseInput,
+	base string,
+) (*big.Rat, error) {
+
+	if input.Currency == base {
+		return big.NewRat(1, 1), nil
+	}
+
+	var rate *big.Rat
+	var err error
+	switch {
+	case input.ExchangeRate != "":
+		if rate, err = parseRate(input.ExchangeRate); err != nil {
+			return nil, NewValidationError("exchange_rate", err.Error())
+		}
+	case l.rates != nil:
+		// a provider without the currency cannot price this expense
+		if rate, err = l.rates.Rate(ctx, input.Currency, base); err != nil {
+			return nil, NewValidationError("currency", err.Error())
+		}
+	default:
+		return nil, invalidf("exchange_rate", "no exchange rate available for %s to %s", input.Currency, base)
+	}
+	if rate, err = roundRate(rate); err != nil {
+		return nil, NewValidationError("exchange_rate", err.Error())
+	}
+	return rate, nil
+}
+
+// convertShares spreads baseTotal across participants in proportion to their
+// shares in the expense currency, so the converted shares still sum exactly
+// to the converted total.
+func convertShares(
+	shares map[string]Money,
+	baseTotal Money,
+	strategy RemainderStrategy,
+	payer string,
+) (map[string]Money, error) {
+
+	weights := make([]weight, 0, len(shares))
+	converted := make(map[string]Money, len(shares))
+	for userID, amount := range shares {
+		if amount.IsZero() {
+			converted[userID] = NewMoney(0, baseTotal.Currency)
+			continue
+		}
+		weights = append(weights, weight{userID: userID, weight: amount.Minor})
+	}
+
+	allocated, err := allocate(baseTotal, weights, strategy, payer)
+	if err != nil {
+		return nil, err
+	}
+	for userID, amount := range allocated {
+		converted[userID] = amount
+	}
+	return converted, nil
+}
@@ -0,0 +1,235 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizePayers_SinglePaidBy(t *testing.T) {
+	input := ExpenseInput{TotalAmount: inr(5000), Currency: DefaultCurrency, PaidBy: "u1"}
+
+	payers, err := normalizePayers(&input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(payers) != 1 || payers["u1"] != inr(5000) {
+		t.Errorf("unexpected payers: %v", payers)
+	}
+}
+
+func TestNormalizePayers_MultiplePayers(t *testing.T) {
+	input := ExpenseInput{
+		TotalAmount: inr(10000),
+		Currency:    DefaultCurrency,
+		Payers: []PayerInput{
+			{UserID: "u2", Amount: inr(4000)},
+			{UserID: "u1", Amount: inr(6000)},
+		},
+	}
+
+	payers, err := normalizePayers(&input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if payers["u1"] != inr(6000) || payers["u2"] != inr(4000) {
+		t.Errorf("unexpected payers: %v", payers)
+	}
+	if input.PaidBy != "u1" {
+		t.Errorf("primary payer = %q, want the largest payer u1", input.PaidBy)
+	}
+}
+
+func TestNormalizePayers_InvalidSum(t *testing.T) {
+	input := ExpenseInput{
+		TotalAmount: inr(10000),
+		Currency:    DefaultCurrency,
+		Payers: []PayerInput{
+			{UserID: "u1", Amount: inr(6000)},
+			{UserID: "u2", Amount: inr(3000)},
+		},
+	}
+
+	if _, err := normalizePayers(&input); err == nil {
+		t.Errorf("expected error when payer amounts do not sum to the total")
+	}
+}
+
+func TestExpenseTransfers_TwoPayers(t *testing.T) {
+	// Dinner for 90: u1 paid 60, u2 paid 30, split equally three ways.
+	paid := map[string]Money{"u1": inr(6000), "u2": inr(3000)}
+	owed := map[string]Money{"u1": inr(3000), "u2": inr(3000), "u3": inr(3000)}
+
+	got := expenseTransfers(paid, owed)
+	want := []transfer{{from: "u3", to: "u1", minor: 3000}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expenseTransfers() = %+v, want %+v", got, want)
+	}
+}
+
+func TestCreateExpense_RejectsNonMembers(t *testing.T) {
+	ctx := context.Background()
+	l, store := newTestLedger(t)
+	if err := store.AddUser("u4", "User u4", "u4@test.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	// u4 exists but is not in g1; u9 does not exist at all
+	input := equalExpense("e1", "g1", "u4", 9000, "u1", "u9", "u2")
+	err := l.CreateExpense(ctx, input)
+
+	var membership *MembershipError
+	if !errors.As(err, &membership) {
+		t.Fatalf("CreateExpense() error = %v, want a *MembershipError", err)
+	}
+	if want := []string{"u4", "u9"}; membership.GroupID != "g1" || !reflect.DeepEqual(membership.UserIDs, want) {
+		t.Errorf("MembershipError = %+v, want group g1 and users %v", membership, want)
+	}
+	if _, err := l.GetExpense(ctx, "e1"); !errors.Is(err, ErrExpenseNotFound) {
+		t.Errorf("rejected expense was recorded: %v", err)
+	}
+}
+
+func TestCreateExpense_DirectMustBeExplicit(t *testing.T) {
+	ctx := context.Background()
+	l, store := newTestLedger(t)
+	if err := store.AddUser("u4", "User u4", "u4@test.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	input := equalExpense("e1", DirectScope, "u1", 9000, "u1", "u4")
+	input.Direct = false
+	if err := l.CreateExpense(ctx, input); err == nil {
+		t.Fatal("expected error for an expense with neither group_id nor direct")
+	}
+
+	// a direct expense needs no shared group
+	input.Direct = true
+	if err := l.CreateExpense(ctx, input); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateExpense_ReversesBalancesExactly(t *testing.T) {
+	ctx := context.Background()
+
+	exact := ExpenseInput{
+		GroupID:      "g1",
+		PaidBy:       "u1",
+		TotalAmount:  inr(9000),
+		SplitType:    SplitExact,
+		Participants: []string{"u1", "u2", "u3"},
+		Splits: []SplitInput{
+			{UserID: "u1", Amount: inr(1000)},
+			{UserID: "u2", Amount: inr(5000)},
+			{UserID: "u3", Amount: inr(3000)},
+		},
+	}
+
+	tests := []struct {
+		name   string
+		update ExpenseInput
+		want   map[string][]BalanceView
+	}{
+		{
+			name:   "payer changes",
+			update: equalExpense("", "g1", "u2", 9000, "u1", "u2", "u3"),
+			want: map[string][]BalanceView{
+				"g1": {balanceRow("g1", "u1", "u2", inr(3000)), balanceRow("g1", "u3", "u2", inr(4000))},
+				"g2": {},
+			},
+		},
+		{
+			name:   "moves to another group",
+			update: equalExpense("", "g2", "u1", 9000, "u1", "u2", "u3"),
+			want: map[string][]BalanceView{
+				"g1": {balanceRow("g1", "u3", "u2", inr(1000))},
+				"g2": {balanceRow("g2", "u2", "u1", inr(3000)), balanceRow("g2", "u3", "u1", inr(3000))},
+			},
+		},
+		{
+			name:   "split type changes",
+			update: exact,
+			want: map[string][]BalanceView{
+				"g1": {
+					balanceRow("g1", "u2", "u1", inr(5000)),
+					balanceRow("g1", "u3", "u1", inr(3000)),
+					balanceRow("g1", "u3", "u2", inr(1000)),
+				},
+				"g2": {},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := newExpenseEditLedger(t)
+			if err := l.UpdateExpense(ctx, "e1", tt.update); err != nil {
+				t.Fatal(err)
+			}
+			assertGroupBalances(t, l, tt.want)
+		})
+	}
+}
+
+func TestDeleteExpense_ReversesBalancesExactly(t *testing.T) {
+	ctx := context.Background()
+	l := newExpenseEditLedger(t)
+
+	if err := l.DeleteExpense(ctx, "e1"); err != nil {
+		t.Fatal(err)
+	}
+	assertGroupBalances(t, l, map[string][]BalanceView{
+		"g1": {balanceRow("g1", "u3", "u2", inr(1000))},
+		"g2": {},
+	})
+}
+
+// newExpenseEditLedger returns a test ledger with a second group g2 and two
+// expenses in g1: e0, where u3 owes u2 10, and e1, where u2 and u3 each owe
+// u1 30.
+func newExpenseEditLedger(t *testing.T) *Ledger {
+	t.Helper()
+	ctx := context.Background()
+	l, store := newTestLedger(t)
+	if err := store.AddGroup("g2", "Flat", DefaultCurrency); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"u1", "u2", "u3"} {
+		if err := store.AddGroupMember("g2", id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := l.CreateExpense(ctx, equalExpense("e0", "g1", "u2", 2000, "u2", "u3")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.CreateExpense(ctx, equalExpense("e1", "g1", "u1", 9000, "u1", "u2", "u3")); err != nil {
+		t.Fatal(err)
+	}
+	assertGroupBalances(t, l, map[string][]BalanceView{
+		"g1": {
+			balanceRow("g1", "u2", "u1", inr(3000)),
+			balanceRow("g1", "u3", "u1", inr(3000)),
+			balanceRow("g1", "u3", "u2", inr(1000)),
+		},
+	})
+	return l
+}
+
+// assertGroupBalances checks each group's balances against want.
+func assertGroupBalances(t *testing.T, l *Ledger, want map[string][]BalanceView) {
+	t.Helper()
+	for groupID, rows := range want {
+		got, err := l.GetGroupBalances(context.Background(), groupID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(got) == 0 && len(rows) == 0 {
+			continue
+		}
+		if !reflect.DeepEqual(got, rows) {
+			t.Errorf("%s balances = %+v, want %+v", groupID, got, rows)
+		}
+	}
+}
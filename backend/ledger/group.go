@@ -0,0 +1,246 @@
+package ledger
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrGroupNotFound is returned for a group ID that does not exist.
+	ErrGroupNotFound error = notFound("group_not_found", "group not found")
+	// ErrGroupArchived is returned for changes to an archived group.
+	ErrGroupArchived error = conflict("group_archived", "group is archived")
+	// ErrGroupHasBalances is returned when archiving a group whose members
+	// still owe each other money.
+	ErrGroupHasBalances error = conflict("group_has_balances", "group has outstanding balances")
+	// ErrNotGroupMember is returned when removing a user who is not a
+	// member of the group.
+	ErrNotGroupMember error = notFound("not_group_member", "user is not a member of the group")
+	// ErrMemberHasBalances is returned when removing a member who still
+	// owes or is owed money within the group.
+	ErrMemberHasBalances error = conflict("member_has_balances", "member has outstanding balances in the group")
+)
+
+// groupSettings holds the per-group options that shape how expenses and
+// balances are recorded. The direct scope uses the defaults.
+type groupSettings struct {
+	baseCurrency      string
+	remainderStrategy RemainderStrategy
+	simplifyDebts     bool
+	// simplified is set once the group's balances have been simplified,
+	// after which they no longer follow pair by pair from its history
+	simplified bool
+	// archived groups take no new expenses or membership changes
+	archived bool
+}
+
+var defaultGroupSettings = groupSettings{
+	baseCurrency:      DefaultCurrency,
+	remainderStrategy: DefaultRemainderStrategy,
+}
+
+// simplifyIfEnabled keeps a group with simplify_debts turned on at its
+// minimal set of balances. It runs after every write that touches the
+// group's balances, inside the same transaction; groups without the flag
+// keep their pairwise balances untouched.
+func simplifyIfEnabled(ctx context.Context, tx storeTx, groupID string) error {
+	settings, err := tx.groupSettings(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if !settings.simplifyDebts {
+		return nil
+	}
+	_, err = simplifyGroup(ctx, tx, groupID)
+	return err
+}
+
+// SetGroupSimplifyDebts turns automatic debt simplification on or off for a
+// group. Turning it on simplifies the group's current balances straight
+// away; turning it off keeps the balances as they are, and later writes
+// are netted pairwise again. Archived groups cannot be changed.
+func (l *Ledger) SetGroupSimplifyDebts(ctx context.Context, groupID string, enabled bool) error {
+	return l.withTx(ctx, func(tx storeTx) error {
+		if groupID == DirectScope {
+			return NewValidationError("group_id", "group_id must be provided")
+		}
+		if err := checkGroupActive(ctx, tx, groupID); err != nil {
+			return err
+		}
+
+		if err := tx.setSimplifyDebts(ctx, groupID, enabled); err != nil {
+			return err
+		}
+
+		return simplifyIfEnabled(ctx, tx, groupID)
+	})
+}
+
+// GroupInput holds the fields of a new group.
+type GroupInput struct {
+	Name string `json:"name"`
+	// BaseCurrency is what the group's balances are kept in; it defaults
+	// to DefaultCurrency and cannot be changed later.
+	BaseCurrency string `json:"base_currency,omitempty"`
+	// RemainderStrategy defaults to DefaultRemainderStrategy.
+	RemainderStrategy RemainderStrategy `json:"remainder_strategy,omitempty"`
+	// MemberIDs are the group's first members.
+	MemberIDs []string `json:"member_ids,omitempty"`
+}
+
+// CreateGroup adds a group with a new ID and its first members, and
+// returns it.
+func (l *Ledger) CreateGroup(ctx context.Context, input GroupInput) (GroupView, error) {
+	name, err := normalizeName(input.Name)
+	if err != nil {
+		return GroupView{}, err
+	}
+	currency := normalizeCurrency(input.BaseCurrency)
+	if currency == "" {
+		currency = DefaultCurrency
+	}
+	if !validCurrency(currency) {
+		return GroupView{}, invalidf("base_currency", "invalid currency %q", input.BaseCurrency)
+	}
+	strategy := input.RemainderStrategy
+	if strategy == "" {
+		strategy = DefaultRemainderStrategy
+	}
+	if !strategy.valid() {
+		return GroupView{}, invalidf("remainder_strategy", "invalid remainder strategy %q", strategy)
+	}
+	group := GroupView{
+		ID:                uuid.NewString(),
+		Name:              name,
+		BaseCurrency:      currency,
+		RemainderStrategy: strategy,
+	}
+
+	err = l.withTx(ctx, func(tx storeTx) error {
+		if err := tx.insertGroup(ctx, group); err != nil {
+			return err
+		}
+		for _, userID := range input.MemberIDs {
+			if err := addGroupMember(ctx, tx, group.ID, userID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return GroupView{}, err
+	}
+	return group, nil
+}
+
+// RenameGroup changes a group's name.
+func (l *Ledger) RenameGroup(ctx context.Context, groupID, name string) error {
+	name, err := normalizeName(name)
+	if err != nil {
+		return err
+	}
+	if !l.store.validID(groupID) {
+		return ErrGroupNotFound
+	}
+
+	return l.withTx(ctx, func(tx storeTx) error {
+		if err := checkGroupActive(ctx, tx, groupID); err != nil {
+			return err
+		}
+		return tx.renameGroup(ctx, groupID, name)
+	})
+}
+
+// ArchiveGroup retires a settled-up group. It disappears from GetGroups
+// and takes no new expenses or membership changes, while its history can
+// still be read. A group with outstanding balances cannot be archived:
+// ErrGroupHasBalances is returned. Archiving an archived group changes
+// nothing.
+func (l *Ledger) ArchiveGroup(ctx context.Context, groupID string) error {
+	if !l.store.validID(groupID) {
+		return ErrGroupNotFound
+	}
+
+	return l.withTx(ctx, func(tx storeTx) error {
+		if _, err := tx.groupSettings(ctx, groupID); err != nil {
+			return err
+		}
+		balances, err := tx.scopeBalances(ctx, groupID)
+		if err != nil {
+			return err
+		}
+		if len(balances) > 0 {
+			return ErrGroupHasBalances
+		}
+		return tx.archiveGroup(ctx, groupID)
+	})
+}
+
+// AddGroupMember adds an active user to a group. Adding a member again
+// changes nothing.
+func (l *Ledger) AddGroupMember(ctx context.Context, groupID, userID string) error {
+	if !l.store.validID(groupID) {
+		return ErrGroupNotFound
+	}
+
+	return l.withTx(ctx, func(tx storeTx) error {
+		if err := checkGroupActive(ctx, tx, groupID); err != nil {
+			return err
+		}
+		return addGroupMember(ctx, tx, groupID, userID)
+	})
+}
+
+// RemoveGroupMember takes a user out of a group. A member who still owes
+// or is owed money within the group cannot be removed until those
+// balances are settled: ErrMemberHasBalances is returned. Their past
+// expenses in the group are kept.
+func (l *Ledger) RemoveGroupMember(ctx context.Context, groupID, userID string) error {
+	if !l.store.validID(groupID) {
+		return ErrGroupNotFound
+	}
+	if !l.store.validID(userID) {
+		return ErrNotGroupMember
+	}
+
+	return l.withTx(ctx, func(tx storeTx) error {
+		if err := checkGroupActive(ctx, tx, groupID); err != nil {
+			return err
+		}
+		balances, err := tx.scopeBalances(ctx, groupID)
+		if err != nil {
+			return err
+		}
+		for _, b := range balances {
+			if b.FromUserID == userID || b.ToUserID == userID {
+				return ErrMemberHasBalances
+			}
+		}
+		return tx.deleteGroupMember(ctx, groupID, userID)
+	})
+}
+
+// addGroupMember adds an active user to a group that exists.
+func addGroupMember(ctx context.Context, tx storeTx, groupID, userID string) error {
+	if !tx.validID(userID) {
+		return ErrUserNotFound
+	}
+	if _, err := tx.user(ctx, userID); err != nil {
+		return err
+	}
+	return tx.insertGroupMember(ctx, groupID, userID)
+}
+
+// checkGroupActive returns ErrGroupNotFound or ErrGroupArchived unless the
+// group exists and is not archived.
+func checkGroupActive(ctx context.Context, tx storeTx, groupID string) error {
+	settings, err := tx.groupSettings(ctx, groupID)
+	if err != nil {
+		return err
+	}
+	if settings.archived {
+		return ErrGroupArchived
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// newGroupWithMembers creates a group and one user per name through the
+// ledger, returning the group and the users' IDs.
+func newGroupWithMembers(t *testing.T, l *Ledger, names ...string) (GroupView, []string) {
+	t.Helper()
+	ctx := context.Background()
+	ids := []string{}
+	for _, name := range names {
+		u, err := l.CreateUser(ctx, UserInput{Name: name, Email: name + "@example.com"})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, u.ID)
+	}
+	g, err := l.CreateGroup(ctx, GroupInput{Name: "Flat", BaseCurrency: "usd", MemberIDs: ids})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return g, ids
+}
+
+func TestCreateGroup(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLedger(t)
+
+	g, ids := newGroupWithMembers(t, l, "asha", "ben")
+	if g.BaseCurrency != "USD" {
+		t.Errorf("base currency = %s, want USD", g.BaseCurrency)
+	}
+	members, err := l.GetGroupMembers(ctx, g.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(members) != len(ids) {
+		t.Errorf("group has %d members, want %d", len(members), len(ids))
+	}
+
+	_, err = l.CreateGroup(ctx, GroupInput{Name: "Trip", MemberIDs: []string{"u9"}})
+	if !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("CreateGroup() with an unknown member: error = %v, want %v", err, ErrUserNotFound)
+	}
+}
+
+func TestCreateGroup_RemainderStrategy(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLedger(t)
+
+	g, err := l.CreateGroup(ctx, GroupInput{
+		Name:              "Flat",
+		MemberIDs:         []string{"u1", "u2", "u3"},
+		RemainderStrategy: RemainderPayerFirst,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := l.CreateExpense(ctx, equalExpense("e1", g.ID, "u3", 10001, "u1", "u2", "u3")); err != nil {
+		t.Fatal(err)
+	}
+	expense, err := l.GetExpense(ctx, "e1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, split := range expense.Splits {
+		if split.UserID == "u3" && split.Amount != inr(3335) {
+			t.Errorf("payer's share = %v, want the leftover paise (33.35)", split.Amount)
+		}
+	}
+
+	_, err = l.CreateGroup(ctx, GroupInput{Name: "Trip", RemainderStrategy: "ROUND_ROBIN"})
+	assertKind(t, err, ErrValidation)
+}
+
+func TestRemoveGroupMember_BlockedByBalances(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLedger(t)
+	g, ids := newGroupWithMembers(t, l, "asha", "ben", "chen")
+	asha, ben, chen := ids[0], ids[1], ids[2]
+
+	expense := equalExpense("e1", g.ID, asha, 10000, asha, ben)
+	expense.Currency = "USD"
+	if err := l.CreateExpense(ctx, expense); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.RemoveGroupMember(ctx, g.ID, ben); !errors.Is(err, ErrMemberHasBalances) {
+		t.Fatalf("RemoveGroupMember() error = %v, want %v", err, ErrMemberHasBalances)
+	}
+	// a member outside the open balance can leave
+	if err := l.RemoveGroupMember(ctx, g.ID, chen); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.RemoveGroupMember(ctx, g.ID, chen); !errors.Is(err, ErrNotGroupMember) {
+		t.Errorf("second RemoveGroupMember() error = %v, want %v", err, ErrNotGroupMember)
+	}
+
+	if _, err := l.SettleBalance(ctx, g.ID, ben, asha, NewMoney(5000, "USD")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.RemoveGroupMember(ctx, g.ID, ben); err != nil {
+		t.Errorf("RemoveGroupMember() after settling up: %v", err)
+	}
+}
+
+func TestArchiveGroup(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLedger(t)
+	g, ids := newGroupWithMembers(t, l, "asha", "ben")
+
+	expense := equalExpense("e1", g.ID, ids[0], 10000, ids...)
+	expense.Currency = "USD"
+	if err := l.CreateExpense(ctx, expense); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.ArchiveGroup(ctx, g.ID); !errors.Is(err, ErrGroupHasBalances) {
+		t.Fatalf("ArchiveGroup() error = %v, want %v", err, ErrGroupHasBalances)
+	}
+
+	if _, err := l.SettleBalance(ctx, g.ID, ids[1], ids[0], NewMoney(5000, "USD")); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.ArchiveGroup(ctx, g.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	groups, err := l.GetGroups(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, listed := range groups {
+		if listed.ID == g.ID {
+			t.Error("archived group is still listed")
+		}
+	}
+
+	expense.ExpenseID = "e2"
+	if err := l.CreateExpense(ctx, expense); !errors.Is(err, ErrGroupArchived) {
+		t.Errorf("CreateExpense() in an archived group: error = %v, want %v", err, ErrGroupArchived)
+	}
+	if err := l.DeleteExpense(ctx, "e1"); !errors.Is(err, ErrGroupArchived) {
+		t.Errorf("DeleteExpense() in an archived group: error = %v, want %v", err, ErrGroupArchived)
+	}
+	if err := l.RenameGroup(ctx, g.ID, "Old flat"); !errors.Is(err, ErrGroupArchived) {
+		t.Errorf("RenameGroup() of an archived group: error = %v, want %v", err, ErrGroupArchived)
+	}
+	if _, err := l.SimplifyGroupBalances(ctx, g.ID); !errors.Is(err, ErrGroupArchived) {
+		t.Errorf("SimplifyGroupBalances() of an archived group: error = %v, want %v", err, ErrGroupArchived)
+	}
+	if err := l.SetGroupSimplifyDebts(ctx, g.ID, true); !errors.Is(err, ErrGroupArchived) {
+		t.Errorf("SetGroupSimplifyDebts() on an archived group: error = %v, want %v", err, ErrGroupArchived)
+	}
+}
+
+func TestGroupOperations_UseStoreIDs(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLedger(t)
+
+	// the memory store's IDs are not UUIDs
+	if err := l.RenameGroup(ctx, "g1", "Road trip"); err != nil {
+		t.Errorf("RenameGroup(g1): %v", err)
+	}
+	if err := l.RemoveGroupMember(ctx, "g1", "u3"); err != nil {
+		t.Errorf("RemoveGroupMember(g1, u3): %v", err)
+	}
+	if err := l.AddGroupMember(ctx, "g1", "u3"); err != nil {
+		t.Errorf("AddGroupMember(g1, u3): %v", err)
+	}
+	if err := l.ArchiveGroup(ctx, "g1"); err != nil {
+		t.Errorf("ArchiveGroup(g1): %v", err)
+	}
+}
+
+func TestGroupReads_UnknownGroup(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLedger(t)
+
+	if _, err := l.GetGroupBalances(ctx, "g9"); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("GetGroupBalances(g9) error = %v, want %v", err, ErrGroupNotFound)
+	}
+	if _, err := l.GetGroupMembers(ctx, "g9"); !errors.Is(err, ErrGroupNotFound) {
+		t.Errorf("GetGroupMembers(g9) error = %v, want %v", err, ErrGroupNotFound)
+	}
+}
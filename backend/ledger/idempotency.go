@@ -0,0 +1,42 @@
+package ledger
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// ErrExpenseConflict is returned when an expense_id is reused for an
+// expense that differs from the one already recorded under it.
+var ErrExpenseConflict error = conflict("expense_conflict", "expense_id already used for a different expense")
+
+// ErrSettlementConflict is returned when a settlement ID is reused for a
+// payment that differs from the one already recorded under it.
+var ErrSettlementConflict error = conflict("settlement_conflict", "settlement_id already used for a different settlement")
+
+// idempotencyNamespace scopes the IDs derived from idempotency keys.
+var idempotencyNamespace = uuid.MustParse("5b0f3c1e-7a4d-4c36-9d0e-2f6a8b1c4e90")
+
+// IDFromIdempotencyKey maps a client's Idempotency-Key to a stable record
+// ID. Retrying a request with the same key therefore targets the same
+// expense or settlement, which is recorded only once.
+func IDFromIdempotencyKey(key string) string {
+	return uuid.NewSHA1(idempotencyNamespace, []byte(key)).String()
+}
+
+// expenseRequestHash fingerprints the request that created or last updated
+// an expense, so a retry can be told apart from a different expense reusing
+// the same ID.
+func expenseRequestHash(input ExpenseInput) (string, error) {
+	// Direct says nothing GroupID does not; leaving it out keeps matching
+	// direct expenses recorded before the flag existed
+	input.Direct = false
+	data, err := json.Marshal(input)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
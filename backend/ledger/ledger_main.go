@@ -3,35 +3,54 @@ package ledger
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
-type Ledger struct{
-	db *sql.DB
+type Ledger struct {
+	store Store
+	rates RateProvider
 }
 
-//creating a ledger instance
+// creating a ledger instance backed by Postgres
 func New(db *sql.DB) *Ledger {
-	return &Ledger{db: db}
+	return NewWithStore(NewPostgresStore(db))
 }
 
-// the below function helps in the commiting or reverting of the transaction state
-
-func (l *Ledger) withTx(fn func(tx *sql.Tx) error) error{
-	tx,err := l.db.BeginTx(
-		context.Background(),
-		&sql.TxOptions{
-			Isolation: sql.LevelSerializable,
-		},
-	)
-	if err!=nil{
-		return err
-	}
-	// Ensuring rollback in case of any error or issue uprising 
-	defer tx.Rollback()
+// NewWithStore creates a ledger that keeps its records in store, e.g. a
+// MemoryStore for tests and demos.
+func NewWithStore(store Store) *Ledger {
+	return &Ledger{store: store}
+}
+
+// SetRateProvider sets where exchange rates for foreign-currency expenses
+// come from. Without one, expenses must be in the group's base currency or
+// carry an explicit exchange rate.
+func (l *Ledger) SetRateProvider(rates RateProvider) {
+	l.rates = rates
+}
 
-if err := fn(tx); err != nil {
-		return err
+// the below function helps in the commiting or reverting of the transaction state.
+// fn is run again from the start when the transaction is aborted by a
+// serialization failure or deadlock, so it must not keep state between runs
+// other than by assigning its results.
+
+func (l *Ledger) withTx(ctx context.Context, fn func(tx storeTx) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTxAttempts; attempt++ {
+		if attempt > 1 {
+			timer := time.NewTimer(txBackoff(attempt - 1))
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return ctx.Err()
+			case <-timer.C:
+			}
+		}
+
+		err = l.store.withTx(ctx, fn)
+		if !isRetryable(err) {
+			return err
+		}
 	}
-	// if passing all the cases then commit 
-	return tx.Commit()
-}
\ No newline at end of file
+	return &TxConflictError{Attempts: maxTxAttempts, Err: err}
+}
@@ -0,0 +1,160 @@
+package ledger
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// DefaultCurrency is the currency used when an amount does not name one.
+const DefaultCurrency = "INR"
+
+// minorPerMajor is the number of minor units (cents, paise) in one major unit.
+// Every amount column in the schema is NUMERIC(12, 2).
+const minorPerMajor = 100
+
+// Money is an exact monetary amount held as an integer number of minor
+// units. Amounts never pass through float64, so splits, balances and
+// settlements add up to the cent.
+//
+// On the wire and in SQL a Money is written as a plain decimal with two
+// fraction digits ("33.33"); the currency travels separately.
+type Money struct {
+	Minor    int64
+	Currency string
+}
+
+// NewMoney returns an amount of minor units in the given currency.
+func NewMoney(minor int64, currency string) Money {
+	return Money{Minor: minor, Currency: currency}
+}
+
+// ParseMoney parses a decimal amount such as "12", "12.5" or "-0.05".
+// Amounts with more precision than one minor unit are rejected rather
+// than rounded.
+func ParseMoney(s string, currency string) (Money, error) {
+	s = strings.TrimSpace(s)
+	r, ok := new(big.Rat).SetString(s)
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", s)
+	}
+	r.Mul(r, big.NewRat(minorPerMajor, 1))
+	if !r.IsInt() {
+		return Money{}, fmt.Errorf("amount %q has more than 2 decimal places", s)
+	}
+	if !r.Num().IsInt64() {
+		return Money{}, fmt.Errorf("amount %q is out of range", s)
+	}
+	return Money{Minor: r.Num().Int64(), Currency: currency}, nil
+}
+
+// String formats the amount as a decimal with two fraction digits.
+func (m Money) String() string {
+	minor := m.Minor
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+	}
+	abs := new(big.Int).Abs(big.NewInt(minor))
+	major, frac := new(big.Int).QuoRem(abs, big.NewInt(minorPerMajor), new(big.Int))
+	return fmt.Sprintf("%s%s.%02d", sign, major.String(), frac.Int64())
+}
+
+func (m Money) IsZero() bool     { return m.Minor == 0 }
+func (m Money) IsPositive() bool { return m.Minor > 0 }
+func (m Money) IsNegative() bool { return m.Minor < 0 }
+
+// Add returns m + o. The result keeps m's currency, falling back to o's.
+func (m Money) Add(o Money) Money {
+	return Money{Minor: m.Minor + o.Minor, Currency: pickCurrency(m, o)}
+}
+
+// Sub returns m - o. The result keeps m's currency, falling back to o's.
+func (m Money) Sub(o Money) Money {
+	return Money{Minor: m.Minor - o.Minor, Currency: pickCurrency(m, o)}
+}
+
+// Neg returns -m.
+func (m Money) Neg() Money {
+	return Money{Minor: -m.Minor, Currency: m.Currency}
+}
+
+// Cmp compares the two amounts and returns -1, 0 or +1.
+func (m Money) Cmp(o Money) int {
+	switch {
+	case m.Minor < o.Minor:
+		return -1
+	case m.Minor > o.Minor:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func pickCurrency(m, o Money) string {
+	if m.Currency != "" {
+		return m.Currency
+	}
+	return o.Currency
+}
+
+// MarshalJSON writes the amount as a JSON number, e.g. 33.33.
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string. The currency
+// already set on m is preserved.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	parsed, err := ParseMoney(s, m.Currency)
+	if err != nil {
+		return err
+	}
+	m.Minor = parsed.Minor
+	return nil
+}
+
+// Scan reads a NUMERIC column. The currency already set on m is preserved.
+func (m *Money) Scan(src any) error {
+	switch v := src.(type) {
+	case nil:
+		m.Minor = 0
+		return nil
+	case int64:
+		m.Minor = v * minorPerMajor
+		return nil
+	case float64:
+		m.Minor = int64(math.Round(v * minorPerMajor))
+		return nil
+	case []byte:
+		return m.scanString(string(v))
+	case string:
+		return m.scanString(v)
+	default:
+		return fmt.Errorf("cannot scan %T into Money", src)
+	}
+}
+
+func (m *Money) scanString(s string) error {
+	parsed, err := ParseMoney(s, m.Currency)
+	if err != nil {
+		return err
+	}
+	m.Minor = parsed.Minor
+	return nil
+}
+
+// Value writes the amount as a decimal string so NUMERIC stores it exactly.
+func (m Money) Value() (driver.Value, error) {
+	return m.String(), nil
+}
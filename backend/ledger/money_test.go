@@ -0,0 +1,79 @@
+package ledger
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := map[string]int64{
+		"12":     1200,
+		"12.5":   1250,
+		"33.33":  3333,
+		"-0.05":  -5,
+		"1e2":    10000,
+		" 0.10 ": 10,
+	}
+	for in, want := range cases {
+		m, err := ParseMoney(in, DefaultCurrency)
+		if err != nil {
+			t.Fatalf("ParseMoney(%q): unexpected error: %v", in, err)
+		}
+		if m.Minor != want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", in, m.Minor, want)
+		}
+	}
+}
+
+func TestParseMoney_RejectsSubMinorPrecision(t *testing.T) {
+	for _, in := range []string{"33.333", "0.001", "abc", ""} {
+		if _, err := ParseMoney(in, DefaultCurrency); err == nil {
+			t.Errorf("ParseMoney(%q): expected error", in)
+		}
+	}
+}
+
+func TestMoney_String(t *testing.T) {
+	cases := map[int64]string{
+		0:     "0.00",
+		5:     "0.05",
+		-5:    "-0.05",
+		3333:  "33.33",
+		10000: "100.00",
+	}
+	for minor, want := range cases {
+		if got := inr(minor).String(); got != want {
+			t.Errorf("Money(%d).String() = %q, want %q", minor, got, want)
+		}
+	}
+}
+
+func TestMoney_JSONRoundTrip(t *testing.T) {
+	var input SplitInput
+	if err := json.Unmarshal([]byte(`{"user_id":"u1","amount":33.33}`), &input); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if input.Amount.Minor != 3333 {
+		t.Fatalf("decoded amount = %d, want 3333", input.Amount.Minor)
+	}
+
+	out, err := json.Marshal(input)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(out) != `{"user_id":"u1","amount":33.33}` {
+		t.Errorf("unexpected encoding: %s", out)
+	}
+}
+
+func TestMoney_Scan(t *testing.T) {
+	for _, src := range []any{"33.33", []byte("33.33"), 33.33} {
+		m := Money{Currency: DefaultCurrency}
+		if err := m.Scan(src); err != nil {
+			t.Fatalf("Scan(%#v): unexpected error: %v", src, err)
+		}
+		if m != inr(3333) {
+			t.Errorf("Scan(%#v) = %v, want 33.33 INR", src, m)
+		}
+	}
+}
@@ -1,57 +1,85 @@
 package ledger
 
+import (
+	"context"
+	"encoding/base64"
+	"sort"
+	"strings"
+	"time"
+)
 
+// BalanceView is one directional balance inside a group scope. GroupID is
+// empty for the direct (non-group) scope. Amounts are in the scope's base
+// currency.
 type BalanceView struct {
-    FromUserID string  `json:"from_user_id"`
-    ToUserID   string  `json:"to_user_id"`
-    Amount     float64 `json:"amount"`
+	GroupID    string `json:"group_id,omitempty"`
+	FromUserID string `json:"from_user_id"`
+	ToUserID   string `json:"to_user_id"`
+	Amount     Money  `json:"amount"`
+	Currency   string `json:"currency"`
 }
 
-
-func (l *Ledger) GetUserBalances(userID string) ([]BalanceView, error) {
-	rows, err := l.db.Query(`
-		SELECT from_user_id, to_user_id, amount
-		FROM balances
-		WHERE from_user_id = $1 OR to_user_id = $1
-		ORDER BY from_user_id, to_user_id
-	`, userID)
-	if err != nil {
+// GetUserBalances returns every balance the user is part of, one row per
+// group scope.
+func (l *Ledger) GetUserBalances(ctx context.Context, userID string) ([]BalanceView, error) {
+	if err := checkIDs(l.store, "user_id", userID); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
-
-	balances := []BalanceView{}
+	return l.store.userBalances(ctx, userID)
+}
 
-	for rows.Next() {
-		var b BalanceView
-		if err := rows.Scan(&b.FromUserID, &b.ToUserID, &b.Amount); err != nil {
-			return nil, err
-		}
-		balances = append(balances, b)
+// GetGroupBalances returns the balances recorded within a single group.
+// Debts from other groups between the same members are not included.
+func (l *Ledger) GetGroupBalances(ctx context.Context, groupID string) ([]BalanceView, error) {
+	if err := checkIDs(l.store, "group_id", groupID); err != nil {
+		return nil, err
 	}
-
-	return balances, nil
+	if _, err := l.store.groupSettings(ctx, groupID); err != nil {
+		return nil, err
+	}
+	return l.store.scopeBalances(ctx, groupID)
 }
 
-func (l *Ledger) GetGroupBalances(groupID string) ([]BalanceView, error) {
-	rows, err := l.db.Query(`
-		SELECT b.from_user_id, b.to_user_id, b.amount
-		FROM balances b
-		JOIN group_members gm1 ON gm1.user_id = b.from_user_id
-		JOIN group_members gm2 ON gm2.user_id = b.to_user_id
-		WHERE gm1.group_id = $1 AND gm2.group_id = $1
-		ORDER BY b.from_user_id, b.to_user_id
-	`, groupID)
+// GetAggregatedBalances nets the user's balances across every group scope,
+// returning at most one row per counterparty and currency. Balances in
+// different currencies are not converted. Rows have no GroupID.
+func (l *Ledger) GetAggregatedBalances(ctx context.Context, userID string) ([]BalanceView, error) {
+	rows, err := l.GetUserBalances(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+
+	// owed is positive when the user owes the counterparty.
+	type netKey struct{ counterparty, currency string }
+	owed := map[netKey]int64{}
+	for _, b := range rows {
+		if b.FromUserID == userID {
+			owed[netKey{b.ToUserID, b.Currency}] += b.Amount.Minor
+		} else {
+			owed[netKey{b.FromUserID, b.Currency}] -= b.Amount.Minor
+		}
+	}
+
+	keys := make([]netKey, 0, len(owed))
+	for key, minor := range owed {
+		if minor != 0 {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].counterparty != keys[j].counterparty {
+			return keys[i].counterparty < keys[j].counterparty
+		}
+		return keys[i].currency < keys[j].currency
+	})
 
 	balances := []BalanceView{}
-	for rows.Next() {
-		var b BalanceView
-		if err := rows.Scan(&b.FromUserID, &b.ToUserID, &b.Amount); err != nil {
-			return nil, err
+	for _, key := range keys {
+		net := NewMoney(owed[key], key.currency)
+
+		b := BalanceView{FromUserID: userID, ToUserID: key.counterparty, Amount: net, Currency: key.currency}
+		if net.IsNegative() {
+			b = BalanceView{FromUserID: key.counterparty, ToUserID: userID, Amount: net.Neg(), Currency: key.currency}
 		}
 		balances = append(balances, b)
 	}
@@ -61,76 +89,250 @@ func (l *Ledger) GetGroupBalances(groupID string) ([]BalanceView, error) {
 
 // UserView
 type UserView struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID    string `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
 }
 
-
 type GroupView struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID                string            `json:"id"`
+	Name              string            `json:"name"`
+	BaseCurrency      string            `json:"base_currency"`
+	SimplifyDebts     bool              `json:"simplify_debts"`
+	RemainderStrategy RemainderStrategy `json:"remainder_strategy"`
 }
 
-func (l *Ledger) GetUsers() ([]UserView, error) {
-	rows, err := l.db.Query(`
-		SELECT id, name FROM users ORDER BY name
-	`)
-	if err != nil {
+func (l *Ledger) GetUsers(ctx context.Context) ([]UserView, error) {
+	return l.store.users(ctx)
+}
+
+func (l *Ledger) GetGroups(ctx context.Context) ([]GroupView, error) {
+	return l.store.groups(ctx)
+}
+
+func (l *Ledger) GetGroupMembers(ctx context.Context, groupID string) ([]UserView, error) {
+	if err := checkIDs(l.store, "group_id", groupID); err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	if _, err := l.store.groupSettings(ctx, groupID); err != nil {
+		return nil, err
+	}
+	return l.store.groupMembers(ctx, groupID)
+}
 
-	users := []UserView{}
-	for rows.Next() {
-		var u UserView
-		if err := rows.Scan(&u.ID, &u.Name); err != nil {
-			return nil, err
-		}
-		users = append(users, u)
+// ExpenseView is a recorded expense. Amount is in the expense's own
+// currency and BaseAmount in its group's base currency. PaidBy is the
+// primary payer. Payers and Splits are only filled in by GetExpense.
+type ExpenseView struct {
+	ID           string             `json:"id"`
+	GroupID      string             `json:"group_id,omitempty"`
+	PaidBy       string             `json:"paid_by"`
+	Amount       Money              `json:"amount"`
+	Currency     string             `json:"currency"`
+	ExchangeRate string             `json:"exchange_rate"`
+	BaseAmount   Money              `json:"base_amount"`
+	BaseCurrency string             `json:"base_currency"`
+	SplitType    SplitType          `json:"split_type"`
+	Tax          Money              `json:"tax,omitzero"`
+	Tip          Money              `json:"tip,omitzero"`
+	Description  string             `json:"description,omitempty"`
+	CreatedAt    time.Time          `json:"created_at"`
+	UpdatedAt    *time.Time         `json:"updated_at,omitempty"`
+	Payers       []ExpensePayerView `json:"payers,omitempty"`
+	Splits       []ExpenseSplitView `json:"splits,omitempty"`
+	Items        []ExpenseItemView  `json:"items,omitempty"`
+}
+
+// ExpensePayerView is how much one payer put towards an expense.
+type ExpensePayerView struct {
+	UserID     string `json:"user_id"`
+	Amount     Money  `json:"amount"`
+	BaseAmount Money  `json:"base_amount"`
+}
+
+// ExpenseSplitView is one participant's share of an expense.
+type ExpenseSplitView struct {
+	UserID     string `json:"user_id"`
+	Amount     Money  `json:"amount"`
+	BaseAmount Money  `json:"base_amount"`
+}
+
+// ExpenseItemView is one receipt line of an ITEMIZED expense and the
+// participants who shared it.
+type ExpenseItemView struct {
+	Name      string   `json:"name"`
+	Amount    Money    `json:"amount"`
+	Consumers []string `json:"consumers"`
+}
+
+// ExpenseFilter narrows ListExpenses. Empty fields do not filter. PaidBy
+// matches any of an expense's payers. From is inclusive and To exclusive.
+type ExpenseFilter struct {
+	GroupID       string
+	PaidBy        string
+	ParticipantID string
+	From          time.Time
+	To            time.Time
+	Cursor        string // NextCursor from the previous page
+	Limit         int
+}
+
+// ExpensePage is one page of ListExpenses results, newest first.
+// NextCursor is empty on the last page.
+type ExpensePage struct {
+	Expenses   []ExpenseView `json:"expenses"`
+	NextCursor string        `json:"next_cursor,omitempty"`
+}
+
+const (
+	defaultPageSize = 50
+	maxPageSize     = 200
+)
+
+// pageLimit clamps a requested page size.
+func pageLimit(limit int) int {
+	if limit <= 0 {
+		return defaultPageSize
 	}
-	return users, nil
+	return min(limit, maxPageSize)
 }
 
-func (l *Ledger) GetGroups() ([]GroupView, error) {
-	rows, err := l.db.Query(`
-		SELECT id, name FROM groups ORDER BY name
-	`)
+// ListExpenses returns expenses matching the filter, newest first, one page
+// at a time. Pass the returned NextCursor back in the filter to continue.
+func (l *Ledger) ListExpenses(ctx context.Context, filter ExpenseFilter) (ExpensePage, error) {
+	err := checkIDs(l.store,
+		"group_id", filter.GroupID,
+		"paid_by", filter.PaidBy,
+		"participant_id", filter.ParticipantID)
 	if err != nil {
-		return nil, err
+		return ExpensePage{}, err
 	}
-	defer rows.Close()
+	limit := pageLimit(filter.Limit)
 
-	groups := []GroupView{}
-	for rows.Next() {
-		var g GroupView
-		if err := rows.Scan(&g.ID, &g.Name); err != nil {
-			return nil, err
-		}
-		groups = append(groups, g)
+	after, err := pageAfter(filter.Cursor)
+	if err != nil {
+		return ExpensePage{}, err
 	}
-	return groups, nil
+
+	expenses, err := l.store.listExpenses(ctx, filter, after, limit+1)
+	if err != nil {
+		return ExpensePage{}, err
+	}
+	page := ExpensePage{Expenses: expenses}
+
+	// one extra row was fetched to tell whether another page exists
+	if len(page.Expenses) > limit {
+		page.Expenses = page.Expenses[:limit]
+		last := page.Expenses[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// GetExpense returns a single expense together with its splits.
+func (l *Ledger) GetExpense(ctx context.Context, expenseID string) (ExpenseView, error) {
+	return l.store.expense(ctx, expenseID)
 }
 
-func (l *Ledger) GetGroupMembers(groupID string) ([]UserView, error) {
-	rows, err := l.db.Query(`
-		SELECT u.id, u.name
-		FROM users u
-		JOIN group_members gm ON gm.user_id = u.id
-		WHERE gm.group_id = $1
-		ORDER BY u.name
-	`, groupID)
+// SettlementView is a recorded payment between two users.
+type SettlementView struct {
+	ID         string    `json:"id"`
+	GroupID    string    `json:"group_id,omitempty"`
+	FromUserID string    `json:"from_user_id"`
+	ToUserID   string    `json:"to_user_id"`
+	Amount     Money     `json:"amount"`
+	Currency   string    `json:"currency"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// SettlementFilter narrows ListSettlements. Empty fields do not filter.
+// UserID matches either side of a settlement; with CounterpartyID also set
+// only settlements between the two users (in either direction) match.
+// From is inclusive and To exclusive.
+type SettlementFilter struct {
+	GroupID        string
+	UserID         string
+	CounterpartyID string
+	From           time.Time
+	To             time.Time
+	Cursor         string // NextCursor from the previous page
+	Limit          int
+}
+
+// SettlementPage is one page of ListSettlements results, newest first.
+// NextCursor is empty on the last page.
+type SettlementPage struct {
+	Settlements []SettlementView `json:"settlements"`
+	NextCursor  string           `json:"next_cursor,omitempty"`
+}
+
+// ListSettlements returns the settlement history matching the filter,
+// newest first, one page at a time.
+func (l *Ledger) ListSettlements(ctx context.Context, filter SettlementFilter) (SettlementPage, error) {
+	if filter.CounterpartyID != "" && filter.UserID == "" {
+		return SettlementPage{}, NewValidationError("counterparty_id", "counterparty requires a user")
+	}
+	err := checkIDs(l.store,
+		"group_id", filter.GroupID,
+		"user_id", filter.UserID,
+		"counterparty_id", filter.CounterpartyID)
 	if err != nil {
-		return nil, err
+		return SettlementPage{}, err
 	}
-	defer rows.Close()
+	limit := pageLimit(filter.Limit)
 
-	users := []UserView{}
-	for rows.Next() {
-		var u UserView
-		if err := rows.Scan(&u.ID, &u.Name); err != nil {
-			return nil, err
-		}
-		users = append(users, u)
+	after, err := pageAfter(filter.Cursor)
+	if err != nil {
+		return SettlementPage{}, err
+	}
+
+	settlements, err := l.store.listSettlements(ctx, filter, after, limit+1)
+	if err != nil {
+		return SettlementPage{}, err
+	}
+	page := SettlementPage{Settlements: settlements}
+
+	// one extra row was fetched to tell whether another page exists
+	if len(page.Settlements) > limit {
+		page.Settlements = page.Settlements[:limit]
+		last := page.Settlements[limit-1]
+		page.NextCursor = encodeCursor(last.CreatedAt, last.ID)
+	}
+	return page, nil
+}
+
+// encodeCursor packs the (created_at, id) sort key of the last row on a
+// page into an opaque token.
+func encodeCursor(createdAt time.Time, id string) string {
+	raw := createdAt.UTC().Format(time.RFC3339Nano) + "|" + id
+	return base64.RawURLEncoding.EncodeToString([]byte(raw))
+}
+
+func decodeCursor(cursor string) (time.Time, string, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return time.Time{}, "", NewValidationError("cursor", "invalid cursor")
+	}
+	ts, id, ok := strings.Cut(string(raw), "|")
+	if !ok || id == "" {
+		return time.Time{}, "", NewValidationError("cursor", "invalid cursor")
+	}
+	createdAt, err := time.Parse(time.RFC3339Nano, ts)
+	if err != nil {
+		return time.Time{}, "", NewValidationError("cursor", "invalid cursor")
+	}
+	return createdAt, id, nil
+}
+
+// pageAfter decodes a cursor into the sort key to resume after; an empty
+// cursor starts from the first page.
+func pageAfter(cursor string) (*pageKey, error) {
+	if cursor == "" {
+		return nil, nil
+	}
+	createdAt, id, err := decodeCursor(cursor)
+	if err != nil {
+		return nil, err
 	}
-	return users, nil
+	return &pageKey{createdAt: createdAt, id: id}, nil
 }
@@ -0,0 +1,260 @@
+package ledger
+
+import (
+	"context"
+	"maps"
+	"sort"
+)
+
+// BalanceDiscrepancy is a user pair whose stored balance differs from the
+// one derived from expense and settlement history. Amounts are signed:
+// positive means FromUserID owes ToUserID, negative the reverse.
+type BalanceDiscrepancy struct {
+	GroupID    string `json:"group_id,omitempty"`
+	FromUserID string `json:"from_user_id"`
+	ToUserID   string `json:"to_user_id"`
+	Expected   Money  `json:"expected"`
+	Actual     Money  `json:"actual"`
+	Currency   string `json:"currency"`
+}
+
+// pairKey identifies an unordered pair of users within a balance scope,
+// with low < high.
+type pairKey struct {
+	groupID string
+	low     string
+	high    string
+}
+
+// pairBalances holds net balances in minor units; a positive value means
+// low owes high.
+type pairBalances map[pairKey]int64
+
+// add records that from owes to minor more within the scope.
+func (p pairBalances) add(groupID string, from string, to string, minor int64) {
+	if from == to || minor == 0 {
+		return
+	}
+	if from < to {
+		p[pairKey{groupID, from, to}] += minor
+	} else {
+		p[pairKey{groupID, to, from}] -= minor
+	}
+}
+
+// net returns each user's net position within a scope: positive when they
+// are owed money, negative when they owe. Users who are even are left out.
+func (p pairBalances) net(groupID string) map[string]int64 {
+	net := map[string]int64{}
+	for key, minor := range p {
+		if key.groupID != groupID {
+			continue
+		}
+		net[key.low] -= minor
+		net[key.high] += minor
+	}
+	maps.DeleteFunc(net, func(_ string, minor int64) bool { return minor == 0 })
+	return net
+}
+
+// simplify replaces a scope's pairs with the minimal set of transfers that
+// settles the same net positions.
+func (p pairBalances) simplify(groupID string) {
+	net := p.net(groupID)
+	p.replaceScope(groupID, pairBalances{})
+	for _, t := range minimizeTransfers(net) {
+		p.add(groupID, t.from, t.to, t.minor)
+	}
+}
+
+// replaceScope replaces a scope's pairs with those of the same scope in
+// other.
+func (p pairBalances) replaceScope(groupID string, other pairBalances) {
+	maps.DeleteFunc(p, func(key pairKey, _ int64) bool { return key.groupID == groupID })
+	for key, minor := range other {
+		if key.groupID == groupID {
+			p[key] = minor
+		}
+	}
+}
+
+// RebuildBalances discards the balances table and recomputes it purely from
+// expense_splits and settlements. Groups with simplify_debts enabled are
+// rebuilt in their simplified form, and groups simplified earlier keep
+// their balances unless they disagree with history (see
+// loadBalanceStates). It returns the pairs that changed.
+func (l *Ledger) RebuildBalances(ctx context.Context) ([]BalanceDiscrepancy, error) {
+	var changed []BalanceDiscrepancy
+	err := l.withTx(ctx, func(tx storeTx) error {
+
+		expected, actual, currencies, err := loadBalanceStates(ctx, tx)
+		if err != nil {
+			return err
+		}
+		changed = diffBalances(expected, actual, currencies)
+
+		if err := tx.deleteAllBalances(ctx); err != nil {
+			return err
+		}
+
+		for _, key := range sortedPairs(expected) {
+			net := expected[key]
+			if net == 0 {
+				continue
+			}
+			from, to := key.low, key.high
+			if net < 0 {
+				from, to, net = to, from, -net
+			}
+			currency := scopeCurrency(currencies, key.groupID)
+			err := tx.putBalance(ctx, balanceRow(key.groupID, from, to, NewMoney(net, currency)))
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return changed, nil
+}
+
+// VerifyBalances recomputes balances from history, like RebuildBalances,
+// and reports every pair where the balances table disagrees. Nothing is
+// written.
+func (l *Ledger) VerifyBalances(ctx context.Context) ([]BalanceDiscrepancy, error) {
+	var discrepancies []BalanceDiscrepancy
+	err := l.withTx(ctx, func(tx storeTx) error {
+		expected, actual, currencies, err := loadBalanceStates(ctx, tx)
+		if err != nil {
+			return err
+		}
+		discrepancies = diffBalances(expected, actual, currencies)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return discrepancies, nil
+}
+
+// loadBalanceStates returns the balances derived from history, the balances
+// currently stored, and the base currency of every group.
+func loadBalanceStates(ctx context.Context, tx storeTx) (expected pairBalances, actual pairBalances, currencies map[string]string, err error) {
+	settings, err := tx.allGroupSettings(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	currencies = map[string]string{}
+	for groupID, s := range settings {
+		currencies[groupID] = s.baseCurrency
+	}
+
+	// every participant owes the payers their share, computed exactly as
+	// when the expense was recorded
+	expected = pairBalances{}
+	expenses, err := tx.allExpenseShares(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, e := range expenses {
+		paid, owed := baseAmounts(e)
+		for _, t := range expenseTransfers(paid, owed) {
+			expected.add(e.GroupID, t.from, t.to, t.minor)
+		}
+	}
+
+	// a settlement pays part of a debt back, i.e. the reverse obligation
+	settlements, err := tx.allSettlements(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, st := range settlements {
+		expected.add(st.GroupID, st.ToUserID, st.FromUserID, st.Amount.Minor)
+	}
+
+	actual = pairBalances{}
+	balances, err := tx.allBalances(ctx)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	for _, b := range balances {
+		actual.add(b.GroupID, b.FromUserID, b.ToUserID, b.Amount.Minor)
+	}
+
+	for groupID, s := range settings {
+		switch {
+		case s.simplifyDebts:
+			// groups that simplify debts store the minimal set of transfers
+			expected.simplify(groupID)
+		case s.simplified:
+			// A group simplified earlier, on request or while it had
+			// simplify_debts on, no longer follows its history pair by
+			// pair. Its balances stand as long as every member's net
+			// position does; otherwise they are rebuilt simplified.
+			if maps.Equal(expected.net(groupID), actual.net(groupID)) {
+				expected.replaceScope(groupID, actual)
+			} else {
+				expected.simplify(groupID)
+			}
+		}
+	}
+
+	return expected, actual, currencies, nil
+}
+
+// diffBalances lists the pairs whose net balance differs between the two
+// states, in a stable order.
+func diffBalances(expected pairBalances, actual pairBalances, currencies map[string]string) []BalanceDiscrepancy {
+	all := pairBalances{}
+	for key := range expected {
+		all[key] = 0
+	}
+	for key := range actual {
+		all[key] = 0
+	}
+
+	discrepancies := []BalanceDiscrepancy{}
+	for _, key := range sortedPairs(all) {
+		if expected[key] == actual[key] {
+			continue
+		}
+		currency := scopeCurrency(currencies, key.groupID)
+		discrepancies = append(discrepancies, BalanceDiscrepancy{
+			GroupID:    key.groupID,
+			FromUserID: key.low,
+			ToUserID:   key.high,
+			Expected:   NewMoney(expected[key], currency),
+			Actual:     NewMoney(actual[key], currency),
+			Currency:   currency,
+		})
+	}
+	return discrepancies
+}
+
+func sortedPairs(p pairBalances) []pairKey {
+	keys := make([]pairKey, 0, len(p))
+	for key := range p {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.groupID != b.groupID {
+			return a.groupID < b.groupID
+		}
+		if a.low != b.low {
+			return a.low < b.low
+		}
+		return a.high < b.high
+	})
+	return keys
+}
+
+// scopeCurrency returns the base currency balances in a scope are kept in.
+func scopeCurrency(currencies map[string]string, groupID string) string {
+	if currency, ok := currencies[groupID]; ok {
+		return currency
+	}
+	return DefaultCurrency
+}
@@ -0,0 +1,67 @@
+package ledger
+
+import "testing"
+
+func TestPairBalances_NetsBothDirections(t *testing.T) {
+	p := pairBalances{}
+	p.add("g1", "u2", "u1", 5000) // u2 owes u1 50
+	p.add("g1", "u1", "u2", 2000) // u1 owes u2 20
+	p.add("g2", "u2", "u1", 700)  // other group, kept apart
+	p.add("g1", "u1", "u1", 100)  // self-debt is ignored
+
+	if got := p[pairKey{"g1", "u1", "u2"}]; got != -3000 {
+		t.Errorf("g1 net = %d, want -3000 (u2 owes u1 30)", got)
+	}
+	if got := p[pairKey{"g2", "u1", "u2"}]; got != -700 {
+		t.Errorf("g2 net = %d, want -700", got)
+	}
+	if len(p) != 2 {
+		t.Errorf("expected 2 pairs, got %v", p)
+	}
+}
+
+func TestDiffBalances(t *testing.T) {
+	expected := pairBalances{}
+	expected.add("g1", "u1", "u2", 1000)
+	expected.add("g1", "u1", "u3", 500)
+
+	actual := pairBalances{}
+	actual.add("g1", "u1", "u2", 1000)
+	actual.add("g1", "u3", "u2", 200)
+
+	got := diffBalances(expected, actual, map[string]string{"g1": "EUR"})
+	if len(got) != 2 {
+		t.Fatalf("expected 2 discrepancies, got %+v", got)
+	}
+
+	if got[0].FromUserID != "u1" || got[0].ToUserID != "u3" ||
+		got[0].Expected.Minor != 500 || got[0].Actual.Minor != 0 {
+		t.Errorf("unexpected first discrepancy: %+v", got[0])
+	}
+	if got[1].FromUserID != "u2" || got[1].ToUserID != "u3" ||
+		got[1].Expected.Minor != 0 || got[1].Actual.Minor != -200 {
+		t.Errorf("unexpected second discrepancy: %+v", got[1])
+	}
+	if got[0].Currency != "EUR" {
+		t.Errorf("currency = %q, want EUR", got[0].Currency)
+	}
+}
+
+func TestPairBalances_SimplifyOnlyTouchesGroup(t *testing.T) {
+	p := pairBalances{}
+	p.add("g1", "a", "b", 100)
+	p.add("g1", "b", "c", 100)
+	p.add("g2", "a", "b", 40)
+
+	p.simplify("g1")
+
+	if got := p[pairKey{"g1", "a", "c"}]; got != 100 {
+		t.Errorf("g1 a->c = %d, want 100", got)
+	}
+	if _, ok := p[pairKey{"g1", "a", "b"}]; ok {
+		t.Errorf("g1 a->b should have been simplified away: %v", p)
+	}
+	if got := p[pairKey{"g2", "a", "b"}]; got != 40 {
+		t.Errorf("g2 a->b = %d, want 40", got)
+	}
+}
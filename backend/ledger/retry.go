@@ -0,0 +1,68 @@
+package ledger
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+)
+
+// Serializable transactions that collide are aborted by Postgres and must
+// be retried from the start. withTx does this up to maxTxAttempts times,
+// sleeping a random duration below an exponentially growing cap between
+// attempts so that colliding writers spread out.
+const (
+	maxTxAttempts  = 5
+	txBackoffBase  = 10 * time.Millisecond
+	txBackoffLimit = 500 * time.Millisecond
+)
+
+// Postgres SQLSTATE codes of errors that are resolved by retrying.
+const (
+	sqlStateSerializationFailure = "40001"
+	sqlStateDeadlockDetected     = "40P01"
+)
+
+// TxConflictError is returned when a transaction kept colliding with
+// concurrent writes and was abandoned after Attempts tries. Err is the
+// last database error. Retrying the request later is safe.
+type TxConflictError struct {
+	Attempts int
+	Err      error
+}
+
+func (e *TxConflictError) Error() string {
+	return fmt.Sprintf("transaction aborted by concurrent updates after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *TxConflictError) Unwrap() error { return e.Err }
+
+// Is makes a TxConflictError match ErrSerialization.
+func (e *TxConflictError) Is(target error) bool { return target == ErrSerialization }
+
+// isRetryable reports whether err is a serialization failure or deadlock.
+// Drivers expose the SQLSTATE through a SQLState method (pgconn.PgError
+// does), which keeps the ledger independent of the driver package.
+func isRetryable(err error) bool {
+	var state interface{ SQLState() string }
+	if !errors.As(err, &state) {
+		return false
+	}
+	switch state.SQLState() {
+	case sqlStateSerializationFailure, sqlStateDeadlockDetected:
+		return true
+	default:
+		return false
+	}
+}
+
+// txBackoff returns how long to wait before the given retry (1 for the
+// first retry): a random duration up to txBackoffBase doubled per retry,
+// never more than txBackoffLimit.
+func txBackoff(retry int) time.Duration {
+	limit := txBackoffBase << min(retry-1, 16)
+	if limit > txBackoffLimit || limit <= 0 {
+		limit = txBackoffLimit
+	}
+	return rand.N(limit) + 1
+}
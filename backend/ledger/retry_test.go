@@ -0,0 +1,119 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+type sqlStateError string
+
+func (e sqlStateError) Error() string    { return "sqlstate " + string(e) }
+func (e sqlStateError) SQLState() string { return string(e) }
+
+func TestIsRetryable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{sqlStateError(sqlStateSerializationFailure), true},
+		{sqlStateError(sqlStateDeadlockDetected), true},
+		{fmt.Errorf("insert: %w", sqlStateError(sqlStateSerializationFailure)), true},
+		{sqlStateError("23505"), false},
+		{errors.New("no outstanding balance to settle"), false},
+		{nil, false},
+	}
+
+	for _, c := range cases {
+		if got := isRetryable(c.err); got != c.want {
+			t.Errorf("isRetryable(%v) = %v, want %v", c.err, got, c.want)
+		}
+	}
+}
+
+func TestTxBackoff_Bounded(t *testing.T) {
+	for retry := 1; retry <= 40; retry++ {
+		limit := min(txBackoffBase<<min(retry-1, 16), txBackoffLimit)
+		for range 100 {
+			if d := txBackoff(retry); d <= 0 || d > limit {
+				t.Fatalf("txBackoff(%d) = %v, want within (0, %v]", retry, d, limit)
+			}
+		}
+	}
+}
+
+func TestTxConflictError_Unwrap(t *testing.T) {
+	cause := sqlStateError(sqlStateSerializationFailure)
+	err := error(&TxConflictError{Attempts: maxTxAttempts, Err: cause})
+
+	var conflict *TxConflictError
+	if !errors.As(err, &conflict) || conflict.Attempts != maxTxAttempts {
+		t.Errorf("expected a TxConflictError, got %v", err)
+	}
+	if !errors.Is(err, cause) {
+		t.Errorf("expected TxConflictError to wrap the database error")
+	}
+}
+
+// conflictingStore is a MemoryStore whose first conflicts transactions
+// fail with a serialization failure, as a colliding Postgres transaction
+// would. onConflict, when set, runs before each failure.
+type conflictingStore struct {
+	*MemoryStore
+	conflicts  int
+	attempts   int
+	onConflict func()
+}
+
+func (s *conflictingStore) withTx(ctx context.Context, fn func(tx storeTx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	s.attempts++
+	if s.attempts <= s.conflicts {
+		if s.onConflict != nil {
+			s.onConflict()
+		}
+		return sqlStateError(sqlStateSerializationFailure)
+	}
+	return s.MemoryStore.withTx(ctx, fn)
+}
+
+func TestLedgerWithTx_RetriesConflicts(t *testing.T) {
+	ctx := context.Background()
+	noop := func(storeTx) error { return nil }
+
+	store := &conflictingStore{MemoryStore: NewMemoryStore(), conflicts: maxTxAttempts - 1}
+	if err := NewWithStore(store).withTx(ctx, noop); err != nil {
+		t.Fatalf("withTx() after %d conflicts: %v", store.conflicts, err)
+	}
+	if store.attempts != maxTxAttempts {
+		t.Errorf("attempts = %d, want %d", store.attempts, maxTxAttempts)
+	}
+
+	store = &conflictingStore{MemoryStore: NewMemoryStore(), conflicts: maxTxAttempts}
+	err := NewWithStore(store).withTx(ctx, noop)
+	var conflict *TxConflictError
+	if !errors.As(err, &conflict) || conflict.Attempts != maxTxAttempts {
+		t.Fatalf("withTx() error = %v, want a TxConflictError after %d attempts", err, maxTxAttempts)
+	}
+	if store.attempts != maxTxAttempts {
+		t.Errorf("attempts = %d, want %d", store.attempts, maxTxAttempts)
+	}
+}
+
+func TestLedgerWithTx_StopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the context is cancelled while the ledger backs off after a conflict
+	store := &conflictingStore{MemoryStore: NewMemoryStore(), conflicts: maxTxAttempts, onConflict: cancel}
+	err := NewWithStore(store).withTx(ctx, func(storeTx) error { return nil })
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("withTx() error = %v, want %v", err, context.Canceled)
+	}
+	if store.attempts != 1 {
+		t.Errorf("attempts = %d, want 1", store.attempts)
+	}
+}
@@ -2,86 +2,167 @@ package ledger
 
 import (
 	"context"
-	"database/sql"
-	"errors"
 
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrNoOutstandingBalance is returned when settling a debt that is not
+	// recorded.
+	ErrNoOutstandingBalance error = &Error{
+		Kind:    ErrInsufficientBalance,
+		Code:    "no_outstanding_balance",
+		Message: "no outstanding balance to settle",
+	}
+	// ErrSettlementExceedsBalance is returned when a settlement is larger
+	// than the debt it settles.
+	ErrSettlementExceedsBalance error = &Error{
+		Kind:    ErrInsufficientBalance,
+		Code:    "settlement_exceeds_balance",
+		Message: "settlement amount exceeds outstanding balance",
+	}
+)
+
 // SettleBalance records a real-world payment and updates the ledger.
+// It returns the ID of the new settlement record.
+// The payment settles the balance within groupID; pass DirectScope for
+// balances that do not belong to a group. The amount is in the scope's base
+// currency; an amount in any other currency is rejected.
 func (l *Ledger) SettleBalance(
 	ctx context.Context,
+	groupID string,
+	fromUserID string,
+	toUserID string,
+	amount Money,
+) (string, error) {
+
+	settlementID := uuid.NewString()
+	err := l.SettleBalanceWithID(ctx, settlementID, groupID, fromUserID, toUserID, amount)
+	if err != nil {
+		return "", err
+	}
+	return settlementID, nil
+}
+
+// SettleBalanceWithID is SettleBalance with a client-chosen settlement ID,
+// which makes retries safe: if a settlement with this ID already records
+// the same payment nothing is applied again, and if it records a different
+// payment ErrSettlementConflict is returned.
+func (l *Ledger) SettleBalanceWithID(
+	ctx context.Context,
+	settlementID string,
+	groupID string,
 	fromUserID string,
 	toUserID string,
-	amount float64,
+	amount Money,
 ) error {
 
-	return l.withTx(func(tx *sql.Tx) error {
+	if settlementID == "" {
+		return NewValidationError("settlement_id", "settlement_id must be provided")
+	}
+	if err := checkIDs(l.store, "settlement_id", settlementID); err != nil {
+		return err
+	}
+
+	return l.withTx(ctx, func(tx storeTx) error {
 
 		// 1️⃣ Validate input
 		if fromUserID == "" || toUserID == "" {
-			return errors.New("user IDs must be provided")
+			return NewValidationError("from_user_id", "user IDs must be provided")
+		}
+		if err := checkIDs(tx, "from_user_id", fromUserID, "to_user_id", toUserID); err != nil {
+			return err
 		}
 		if fromUserID == toUserID {
-			return errors.New("cannot settle balance with self")
+			return NewValidationError("to_user_id", "cannot settle balance with self")
 		}
-		if amount <= 0 {
-			return errors.New("settlement amount must be positive")
+		if !amount.IsPositive() {
+			return NewValidationError("amount", "settlement amount must be positive")
 		}
 
-		// 2️⃣ Fetch existing balance
-		var existing float64
-		err := tx.QueryRow(`
-			SELECT amount
-			FROM balances
-			WHERE from_user_id = $1 AND to_user_id = $2
-		`, fromUserID, toUserID).Scan(&existing)
-
-		if err == sql.ErrNoRows {
-			return errors.New("no outstanding balance to settle")
+		settings, err := tx.groupSettings(ctx, groupID)
+		if err != nil {
+			return err
+		}
+		if amount.Currency == "" {
+			amount.Currency = settings.baseCurrency
+		}
+		if normalizeCurrency(amount.Currency) != settings.baseCurrency {
+			return invalidf("currency", "settlements must be in the group's base currency %s", settings.baseCurrency)
 		}
+		amount.Currency = settings.baseCurrency
+
+		// a retry finds its settlement already recorded
+		duplicate, err := existingSettlement(ctx, tx, settlementID, groupID, fromUserID, toUserID, amount)
+		if err != nil || duplicate {
+			return err
+		}
+
+		// 2️⃣ Fetch existing balance
+		existing, ok, err := tx.balance(ctx, groupID, fromUserID, toUserID)
 		if err != nil {
 			return err
 		}
+		if !ok {
+			return ErrNoOutstandingBalance
+		}
 
-		if amount > existing {
-			return errors.New("settlement amount exceeds outstanding balance")
+		if amount.Cmp(existing) > 0 {
+			return ErrSettlementExceedsBalance
 		}
 
 		// 3️⃣ Reduce or remove balance
-		if amount == existing {
-			_, err = tx.Exec(`
-				DELETE FROM balances
-				WHERE from_user_id = $1 AND to_user_id = $2
-			`, fromUserID, toUserID)
-			if err != nil {
-				return err
-			}
+		if amount.Cmp(existing) == 0 {
+			err = tx.deleteBalance(ctx, groupID, fromUserID, toUserID)
 		} else {
-			_, err = tx.Exec(`
-				UPDATE balances
-				SET amount = amount - $1
-				WHERE from_user_id = $2 AND to_user_id = $3
-			`, amount, fromUserID, toUserID)
-			if err != nil {
-				return err
-			}
+			err = tx.putBalance(ctx, balanceRow(groupID, fromUserID, toUserID, existing.Sub(amount)))
+		}
+		if err != nil {
+			return err
 		}
 
 		// 4️⃣ Insert settlement record (immutable history)
-		_, err = tx.Exec(`
-			INSERT INTO settlements (id, from_user_id, to_user_id, amount)
-			VALUES ($1, $2, $3, $4)
-		`,
-			uuid.NewString(),
-			fromUserID,
-			toUserID,
-			amount,
-		)
+		err = tx.insertSettlement(ctx, SettlementView{
+			ID:         settlementID,
+			GroupID:    groupID,
+			FromUserID: fromUserID,
+			ToUserID:   toUserID,
+			Amount:     amount,
+			Currency:   amount.Currency,
+		})
 		if err != nil {
 			return err
 		}
 
-		return nil
+		// 5️⃣ Keep groups with simplify_debts at their minimal balances
+		return simplifyIfEnabled(ctx, tx, groupID)
 	})
 }
+
+// existingSettlement reports whether settlementID already records exactly
+// this payment. A settlement with the ID but different details is a
+// conflict.
+func existingSettlement(
+	ctx context.Context,
+	tx storeTx,
+	settlementID string,
+	groupID string,
+	fromUserID string,
+	toUserID string,
+	amount Money,
+) (bool, error) {
+
+	stored, ok, err := tx.settlement(ctx, settlementID)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	if stored.GroupID != groupID ||
+		stored.FromUserID != fromUserID ||
+		stored.ToUserID != toUserID ||
+		stored.Amount.Cmp(amount) != 0 ||
+		stored.Currency != amount.Currency {
+		return false, ErrSettlementConflict
+	}
+	return true, nil
+}
@@ -1,139 +1,184 @@
 package ledger
 
-import "database/sql"
-
-type balanceEdge struct {
-	user   string
-	amount float64
+import (
+	"context"
+	"sort"
+)
+
+// transfer is one payment in a simplified set of debts.
+type transfer struct {
+	from  string
+	to    string
+	minor int64
 }
 
-//Helps in the removal of the userid as the intermediate option
-// X -> userID -> Y  ==>  X -> Y
-func SimplifyUserBalances(tx *sql.Tx, userID string) error {
-
-	// Incoming: X -> userID
-	incomingBalances := []balanceEdge{}
-	rowsIn, err := tx.Query(`
-		SELECT from_user_id, amount
-		FROM balances
-		WHERE to_user_id = $1
-		ORDER BY from_user_id
-	`, userID)
-	if err != nil {
-		return err
+// minimizeTransfers settles a set of net positions with as few transfers as
+// a greedy pass allows: the largest debtor repeatedly pays the largest
+// creditor. Positive positions are owed money, negative ones owe money, and
+// they must sum to zero. Ties are broken by user ID so the result is
+// deterministic. At most n-1 transfers are produced for n non-zero users.
+func minimizeTransfers(net map[string]int64) []transfer {
+	type position struct {
+		user  string
+		minor int64 // always positive
 	}
-	defer rowsIn.Close()
 
-	for rowsIn.Next() {
-		var b balanceEdge
-		if err := rowsIn.Scan(&b.user, &b.amount); err != nil {
-			return err
+	var creditors, debtors []position
+	for user, minor := range net {
+		switch {
+		case minor > 0:
+			creditors = append(creditors, position{user, minor})
+		case minor < 0:
+			debtors = append(debtors, position{user, -minor})
 		}
-		incomingBalances = append(incomingBalances, b)
 	}
 
-	// Outgoing: userID -> Y
-	outgoingBalances := []balanceEdge{}
-	rowsOut, err := tx.Query(`
-		SELECT to_user_id, amount
-		FROM balances
-		WHERE from_user_id = $1
-		ORDER BY to_user_id
-	`, userID)
-	if err != nil {
-		return err
+	largestFirst := func(p []position) {
+		sort.Slice(p, func(i, j int) bool {
+			if p[i].minor != p[j].minor {
+				return p[i].minor > p[j].minor
+			}
+			return p[i].user < p[j].user
+		})
 	}
-	defer rowsOut.Close()
 
-	for rowsOut.Next() {
-		var b balanceEdge
-		if err := rowsOut.Scan(&b.user, &b.amount); err != nil {
-			return err
+	transfers := []transfer{}
+	for len(creditors) > 0 && len(debtors) > 0 {
+		largestFirst(creditors)
+		largestFirst(debtors)
+
+		amount := min(creditors[0].minor, debtors[0].minor)
+		transfers = append(transfers, transfer{
+			from:  debtors[0].user,
+			to:    creditors[0].user,
+			minor: amount,
+		})
+
+		creditors[0].minor -= amount
+		debtors[0].minor -= amount
+		if creditors[0].minor == 0 {
+			creditors = creditors[1:]
+		}
+		if debtors[0].minor == 0 {
+			debtors = debtors[1:]
 		}
-		outgoingBalances = append(outgoingBalances, b)
 	}
 
-	// Simplify X -> userID -> Y
-	for i := 0; i < len(incomingBalances); i++ {
-		for j := 0; j < len(outgoingBalances); j++ {
+	return transfers
+}
 
-			transfer := min(incomingBalances[i].amount, outgoingBalances[j].amount)
-			if transfer <= 0 {
-				continue
-			}
+// netPositions loads each member's net balance within a group scope:
+// positive when they are owed money, negative when they owe.
+func netPositions(ctx context.Context, r storeReader, groupID string) (map[string]int64, error) {
+	balances, err := r.scopeBalances(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
 
-			// Reduce X -> userID
-			_, err := tx.Exec(`
-				UPDATE balances
-				SET amount = amount - $1
-				WHERE from_user_id = $2 AND to_user_id = $3
-			`, transfer, incomingBalances[i].user, userID)
-			if err != nil {
-				return err
-			}
+	net := map[string]int64{}
+	for _, b := range balances {
+		net[b.FromUserID] -= b.Amount.Minor
+		net[b.ToUserID] += b.Amount.Minor
+	}
+	return net, nil
+}
 
-			// Reduce userID -> Y
-			_, err = tx.Exec(`
-				UPDATE balances
-				SET amount = amount - $1
-				WHERE from_user_id = $2 AND to_user_id = $3
-			`, transfer, userID, outgoingBalances[j].user)
-			if err != nil {
-				return err
-			}
+// simplifiedBalances turns transfers into balance rows for a scope.
+func simplifiedBalances(groupID string, currency string, transfers []transfer) []BalanceView {
+	balances := make([]BalanceView, 0, len(transfers))
+	for _, t := range transfers {
+		balances = append(balances, BalanceView{
+			GroupID:    groupID,
+			FromUserID: t.from,
+			ToUserID:   t.to,
+			Amount:     NewMoney(t.minor, currency),
+			Currency:   currency,
+		})
+	}
+	return balances
+}
 
-			// Remove zero balances
-			_, err = tx.Exec(`
-				DELETE FROM balances
-				WHERE amount = 0
-			`)
-			if err != nil {
-				return err
-			}
+// PreviewGroupSimplification returns the minimal set of balances that would
+// replace the group's current ones, without writing anything. Every
+// member's net position is unchanged.
+func (l *Ledger) PreviewGroupSimplification(ctx context.Context, groupID string) ([]BalanceView, error) {
+	if err := checkSimplifyScope(l.store, groupID); err != nil {
+		return nil, err
+	}
+	settings, err := l.store.groupSettings(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
 
-			// Add X -> Y
-			if err := applyBalanceDelta(
-				tx,
-				incomingBalances[i].user,
-				outgoingBalances[j].user,
-				transfer,
-			); err != nil {
-				return err
-			}
+	net, err := netPositions(ctx, l.store, groupID)
+	if err != nil {
+		return nil, err
+	}
+	return simplifiedBalances(groupID, settings.baseCurrency, minimizeTransfers(net)), nil
+}
 
-			incomingBalances[i].amount -= transfer
-			outgoingBalances[j].amount -= transfer
-		}
+// SimplifyGroupBalances replaces the group's pairwise balances with the
+// minimal set of transfers that settles every member's net position, and
+// returns the new balances. From then on VerifyBalances and
+// RebuildBalances check the group's net positions rather than its pairs.
+func (l *Ledger) SimplifyGroupBalances(ctx context.Context, groupID string) ([]BalanceView, error) {
+	if err := checkSimplifyScope(l.store, groupID); err != nil {
+		return nil, err
 	}
 
-	return nil
+	var balances []BalanceView
+	err := l.withTx(ctx, func(tx storeTx) error {
+		if err := checkGroupActive(ctx, tx, groupID); err != nil {
+			return err
+		}
+		var err error
+		balances, err = simplifyGroup(ctx, tx, groupID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return balances, nil
 }
 
+// checkSimplifyScope rejects the direct scope, which has no group to record
+// a simplification on, and malformed group IDs.
+func checkSimplifyScope(s storeReader, groupID string) error {
+	if groupID == DirectScope {
+		return NewValidationError("group_id", "group_id must be provided")
+	}
+	return checkIDs(s, "group_id", groupID)
+}
 
-func SimplifyBalances(tx *sql.Tx) error {
+// simplifyGroup rewrites a group's balances as the minimal set of
+// transfers, inside the caller's transaction, and marks the group as
+// simplified.
+func simplifyGroup(ctx context.Context, tx storeTx, groupID string) ([]BalanceView, error) {
+	settings, err := tx.groupSettings(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := tx.Query(`
-		SELECT DISTINCT user_id FROM (
-			SELECT from_user_id AS user_id FROM balances
-			UNION
-			SELECT to_user_id AS user_id FROM balances
-		) u
-	`)
+	net, err := netPositions(ctx, tx, groupID)
 	if err != nil {
-		return err
+		return nil, err
 	}
-	defer rows.Close()
+	balances := simplifiedBalances(groupID, settings.baseCurrency, minimizeTransfers(net))
 
-	var userID string
-	for rows.Next() {
-		if err := rows.Scan(&userID); err != nil {
-			return err
-		}
+	if err := tx.deleteScopeBalances(ctx, groupID); err != nil {
+		return nil, err
+	}
 
-		if err := SimplifyUserBalances(tx, userID); err != nil {
-			return err
+	for _, b := range balances {
+		if err := tx.putBalance(ctx, b); err != nil {
+			return nil, err
 		}
 	}
 
-	return nil
+	if !settings.simplified {
+		if err := tx.markSimplified(ctx, groupID); err != nil {
+			return nil, err
+		}
+	}
+	return balances, nil
 }
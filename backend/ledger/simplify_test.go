@@ -0,0 +1,54 @@
+package ledger
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMinimizeTransfers_Chain(t *testing.T) {
+	// A owes B 100 and B owes C 100: A should pay C directly.
+	net := map[string]int64{"A": -100, "B": 0, "C": 100}
+
+	got := minimizeTransfers(net)
+	want := []transfer{{from: "A", to: "C", minor: 100}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("minimizeTransfers() = %+v, want %+v", got, want)
+	}
+}
+
+func TestMinimizeTransfers_PreservesNetPositions(t *testing.T) {
+	net := map[string]int64{"A": -500, "B": -300, "C": 200, "D": 350, "E": 250}
+
+	transfers := minimizeTransfers(net)
+	if len(transfers) > len(net)-1 {
+		t.Errorf("expected at most %d transfers, got %d", len(net)-1, len(transfers))
+	}
+
+	after := map[string]int64{}
+	for _, tr := range transfers {
+		if tr.minor <= 0 {
+			t.Fatalf("non-positive transfer: %+v", tr)
+		}
+		after[tr.from] -= tr.minor
+		after[tr.to] += tr.minor
+	}
+	for user, minor := range net {
+		if after[user] != minor {
+			t.Errorf("net position of %s = %d, want %d", user, after[user], minor)
+		}
+	}
+}
+
+func TestMinimizeTransfers_DeterministicTieBreak(t *testing.T) {
+	net := map[string]int64{"A": -100, "B": -100, "C": 100, "D": 100}
+
+	want := []transfer{
+		{from: "A", to: "C", minor: 100},
+		{from: "B", to: "D", minor: 100},
+	}
+	for i := 0; i < 20; i++ {
+		if got := minimizeTransfers(net); !reflect.DeepEqual(got, want) {
+			t.Fatalf("minimizeTransfers() = %+v, want %+v", got, want)
+		}
+	}
+}
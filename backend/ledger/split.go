@@ -1,40 +1,54 @@
 package ledger
 
 import (
-	"errors"
 	"math"
 )
 
-const epsilon = 0.01 
+// percentageEpsilon tolerates float noise in client-supplied percentages.
+const percentageEpsilon = 0.01
+
+// percentageScale turns a percentage into an integer weight, keeping four
+// decimal places (33.3333% -> 333333).
+const percentageScale = 10000
+
+// sharesScale turns a share count into an integer weight, keeping four
+// decimal places so half shares (1.5) are allowed.
+const sharesScale = 10000
 
 func calculateEqualSplit(
 	input ExpenseInput,
-	shares map[string]float64,
-) (map[string]float64, error) {
+	strategy RemainderStrategy,
+) (map[string]Money, error) {
 
 	n := len(input.Participants)
 	if n == 0 {
-		return nil, errors.New("no participants provided")
+		return nil, NewValidationError("participants", "no participants provided")
 	}
 
-	share := input.TotalAmount / float64(n)
+	seen := make(map[string]bool, n)
+	weights := make([]weight, 0, n)
 	for _, userID := range input.Participants {
-		shares[userID] = share
+		if seen[userID] {
+			return nil, invalidf("participants", "participant %s is listed more than once", userID)
+		}
+		seen[userID] = true
+		weights = append(weights, weight{userID: userID, weight: 1})
 	}
 
-	return shares, nil
+	return allocate(input.TotalAmount, weights, strategy, input.PaidBy)
 }
 
 func calculateExactSplit(
 	input ExpenseInput,
-	shares map[string]float64,
-) (map[string]float64, error) {
+) (map[string]Money, error) {
+
+	shares := make(map[string]Money)
 
 	if len(input.Splits) == 0 {
-		return nil, errors.New("exact split requires split details")
+		return nil, NewValidationError("splits", "exact split requires split details")
 	}
 
-	var total float64
+	total := NewMoney(0, input.TotalAmount.Currency)
 	participants := make(map[string]bool)
 
 	for _, userID := range input.Participants {
@@ -42,19 +56,79 @@ func calculateExactSplit(
 	}
 
 	for _, split := range input.Splits {
-		if split.Amount <= 0 {
-			return nil, errors.New("split amount must be positive")
+		if !split.Amount.IsPositive() {
+			return nil, NewValidationError("splits", "split amount must be positive")
 		}
 		if !participants[split.UserID] {
-			return nil, errors.New("split user not in participants list")
+			return nil, NewValidationError("splits", "split user not in participants list")
+		}
+		if _, dup := shares[split.UserID]; dup {
+			return nil, NewValidationError("splits", "duplicate amount for user")
+		}
+
+		amount := NewMoney(split.Amount.Minor, input.TotalAmount.Currency)
+		shares[split.UserID] = amount
+		total = total.Add(amount)
+	}
+
+	if total.Cmp(input.TotalAmount) != 0 {
+		return nil, NewValidationError("splits", "sum of exact splits must equal total amount")
+	}
+
+	return shares, nil
+}
+
+// calculateAdjustmentSplit applies each split's signed adjustment and
+// divides what is left of the total equally among all participants.
+func calculateAdjustmentSplit(
+	input ExpenseInput,
+	strategy RemainderStrategy,
+) (map[string]Money, error) {
+
+	if len(input.Splits) == 0 {
+		return nil, NewValidationError("splits", "adjustment split requires split details")
+	}
+
+	adjustments := make(map[string]Money)
+	total := NewMoney(0, input.TotalAmount.Currency)
+	participants := make(map[string]bool)
+
+	for _, userID := range input.Participants {
+		participants[userID] = true
+	}
+
+	for _, split := range input.Splits {
+		if !participants[split.UserID] {
+			return nil, NewValidationError("splits", "split user not in participants list")
+		}
+		if _, dup := adjustments[split.UserID]; dup {
+			return nil, NewValidationError("splits", "duplicate adjustment for user")
 		}
 
-		shares[split.UserID] = split.Amount
-		total += split.Amount
+		adjustment := NewMoney(split.Adjustment.Minor, input.TotalAmount.Currency)
+		adjustments[split.UserID] = adjustment
+		total = total.Add(adjustment)
 	}
 
-	if math.Abs(total-input.TotalAmount) > epsilon {
-		return nil, errors.New("sum of exact splits must equal total amount")
+	remaining := input.TotalAmount.Sub(total)
+	if remaining.IsNegative() {
+		return nil, NewValidationError("splits", "adjustments exceed total amount")
+	}
+
+	equal := input
+	equal.TotalAmount = remaining
+
+	shares, err := calculateEqualSplit(equal, strategy)
+	if err != nil {
+		return nil, err
+	}
+
+	for userID, adjustment := range adjustments {
+		share := shares[userID].Add(adjustment)
+		if share.IsNegative() {
+			return nil, NewValidationError("splits", "adjusted share must not be negative")
+		}
+		shares[userID] = share
 	}
 
 	return shares, nil
@@ -62,57 +136,205 @@ func calculateExactSplit(
 
 func calculatePercentageSplit(
 	input ExpenseInput,
-	shares map[string]float64,
-) (map[string]float64, error) {
+	strategy RemainderStrategy,
+) (map[string]Money, error) {
 
 	if len(input.Splits) == 0 {
-		return nil, errors.New("percentage split requires split details")
+		return nil, NewValidationError("splits", "percentage split requires split details")
 	}
 
 	var totalPercentage float64
 	participants := make(map[string]bool)
+	seen := make(map[string]bool)
 
 	for _, userID := range input.Participants {
 		participants[userID] = true
 	}
 
+	weights := make([]weight, 0, len(input.Splits))
 	for _, split := range input.Splits {
 		if split.Percentage <= 0 {
-			return nil, errors.New("percentage must be positive")
+			return nil, NewValidationError("splits", "percentage must be positive")
+		}
+		// weights keep four decimal places, so anything smaller is lost
+		scaled := math.Round(split.Percentage * percentageScale)
+		if scaled < 1 {
+			return nil, NewValidationError("splits", "percentage must be at least 0.0001")
 		}
 		if !participants[split.UserID] {
-			return nil, errors.New("split user not in participants list")
+			return nil, NewValidationError("splits", "split user not in participants list")
+		}
+		if seen[split.UserID] {
+			return nil, NewValidationError("splits", "duplicate percentage for user")
 		}
+		seen[split.UserID] = true
 
 		totalPercentage += split.Percentage
+		weights = append(weights, weight{userID: split.UserID, weight: int64(scaled)})
 	}
 
-	if math.Abs(totalPercentage-100) > epsilon {
-		return nil, errors.New("sum of percentages must be 100")
+	if math.Abs(totalPercentage-100) > percentageEpsilon {
+		return nil, NewValidationError("splits", "sum of percentages must be 100")
+	}
+
+	return allocate(input.TotalAmount, weights, strategy, input.PaidBy)
+}
+
+func calculateSharesSplit(
+	input ExpenseInput,
+	strategy RemainderStrategy,
+) (map[string]Money, error) {
+
+	if len(input.Splits) == 0 {
+		return nil, NewValidationError("splits", "shares split requires split details")
 	}
 
+	participants := make(map[string]bool)
+	seen := make(map[string]bool)
+
+	for _, userID := range input.Participants {
+		participants[userID] = true
+	}
+
+	weights := make([]weight, 0, len(input.Splits))
 	for _, split := range input.Splits {
-		shares[split.UserID] =
-			input.TotalAmount * (split.Percentage / 100)
+		scaled := math.Round(split.Shares * sharesScale)
+		if scaled < 1 {
+			return nil, NewValidationError("splits", "shares must be positive")
+		}
+		if scaled >= math.MaxInt64 {
+			return nil, NewValidationError("splits", "shares are too large")
+		}
+		if !participants[split.UserID] {
+			return nil, NewValidationError("splits", "split user not in participants list")
+		}
+		if seen[split.UserID] {
+			return nil, NewValidationError("splits", "duplicate shares for user")
+		}
+		seen[split.UserID] = true
+
+		weights = append(weights, weight{userID: split.UserID, weight: int64(scaled)})
+	}
+
+	return allocate(input.TotalAmount, weights, strategy, input.PaidBy)
+}
+
+// calculateItemizedSplit divides each item equally among the participants
+// who consumed it, then distributes tax and tip in proportion to each
+// person's item subtotal.
+func calculateItemizedSplit(
+	input ExpenseInput,
+	strategy RemainderStrategy,
+) (map[string]Money, error) {
+
+	if len(input.Items) == 0 {
+		return nil, NewValidationError("items", "itemized split requires items")
+	}
+	if input.Tax.IsNegative() || input.Tip.IsNegative() {
+		return nil, NewValidationError("tax", "tax and tip must not be negative")
+	}
+
+	currency := input.TotalAmount.Currency
+	participants := make(map[string]bool)
+
+	for _, userID := range input.Participants {
+		participants[userID] = true
 	}
 
+	subtotals := make(map[string]Money)
+	itemsTotal := NewMoney(0, currency)
+
+	for _, item := range input.Items {
+		if item.Name == "" {
+			return nil, NewValidationError("items", "item name must be provided")
+		}
+		if !item.Price.IsPositive() {
+			return nil, NewValidationError("items", "item price must be positive")
+		}
+		if len(item.Consumers) == 0 {
+			return nil, invalidf("items", "item %q has no consumers", item.Name)
+		}
+
+		seen := make(map[string]bool)
+		weights := make([]weight, 0, len(item.Consumers))
+		for _, userID := range item.Consumers {
+			if !participants[userID] {
+				return nil, NewValidationError("items", "item consumer not in participants list")
+			}
+			if seen[userID] {
+				return nil, invalidf("items", "item %q lists consumer %s more than once", item.Name, userID)
+			}
+			seen[userID] = true
+			weights = append(weights, weight{userID: userID, weight: 1})
+		}
+
+		price := NewMoney(item.Price.Minor, currency)
+		parts, err := allocate(price, weights, strategy, input.PaidBy)
+		if err != nil {
+			return nil, err
+		}
+		for userID, part := range parts {
+			subtotals[userID] = subtotals[userID].Add(part)
+		}
+		itemsTotal = itemsTotal.Add(price)
+	}
+
+	extras := NewMoney(input.Tax.Minor+input.Tip.Minor, currency)
+	if itemsTotal.Add(extras).Cmp(input.TotalAmount) != 0 {
+		return nil, NewValidationError("items", "items, tax and tip must add up to total amount")
+	}
+	if extras.IsZero() {
+		return subtotals, nil
+	}
+
+	weights := make([]weight, 0, len(subtotals))
+	for userID, subtotal := range subtotals {
+		// a consumer of a tiny shared item can end up with nothing
+		if subtotal.IsPositive() {
+			weights = append(weights, weight{userID: userID, weight: subtotal.Minor})
+		}
+	}
+
+	parts, err := allocate(extras, weights, strategy, input.PaidBy)
+	if err != nil {
+		return nil, err
+	}
+
+	shares := make(map[string]Money, len(subtotals))
+	for userID, subtotal := range subtotals {
+		shares[userID] = subtotal.Add(parts[userID])
+	}
 	return shares, nil
 }
 
-func calculateShares(input ExpenseInput) (map[string]float64, error) {
-	shares := make(map[string]float64)
+// calculateShares works out how much each participant owes. Leftover minor
+// units from every split type except EXACT are assigned using strategy, so the
+// shares always sum exactly to the total.
+func calculateShares(
+	input ExpenseInput,
+	strategy RemainderStrategy,
+) (map[string]Money, error) {
 
 	switch input.SplitType {
 	case SplitEqual:
-		return calculateEqualSplit(input, shares)
+		return calculateEqualSplit(input, strategy)
 
 	case SplitExact:
-		return calculateExactSplit(input, shares)
+		return calculateExactSplit(input)
+
+	case SplitAdjustment:
+		return calculateAdjustmentSplit(input, strategy)
 
 	case SplitPercentage:
-		return calculatePercentageSplit(input, shares)
+		return calculatePercentageSplit(input, strategy)
+
+	case SplitItemized:
+		return calculateItemizedSplit(input, strategy)
+
+	case SplitShares:
+		return calculateSharesSplit(input, strategy)
 
 	default:
-		return nil, errors.New("invalid split type")
+		return nil, NewValidationError("split_type", "invalid split type")
 	}
 }
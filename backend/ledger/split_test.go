@@ -2,39 +2,43 @@ package ledger
 
 import "testing"
 
+func inr(minor int64) Money {
+	return NewMoney(minor, DefaultCurrency)
+}
+
 func TestCalculateExactSplit_Success(t *testing.T) {
 	input := ExpenseInput{
-		TotalAmount: 300,
-		SplitType:   SplitExact,
+		TotalAmount:  inr(30000),
+		SplitType:    SplitExact,
 		Participants: []string{"u1", "u2"},
 		Splits: []SplitInput{
-			{UserID: "u1", Amount: 100},
-			{UserID: "u2", Amount: 200},
+			{UserID: "u1", Amount: inr(10000)},
+			{UserID: "u2", Amount: inr(20000)},
 		},
 	}
 
-	shares, err := calculateShares(input)
+	shares, err := calculateShares(input, DefaultRemainderStrategy)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if shares["u1"] != 100 || shares["u2"] != 200 {
+	if shares["u1"] != inr(10000) || shares["u2"] != inr(20000) {
 		t.Errorf("incorrect split result: %v", shares)
 	}
 }
 
 func TestCalculateExactSplit_InvalidSum(t *testing.T) {
 	input := ExpenseInput{
-		TotalAmount: 300,
-		SplitType:   SplitExact,
+		TotalAmount:  inr(30000),
+		SplitType:    SplitExact,
 		Participants: []string{"u1", "u2"},
 		Splits: []SplitInput{
-			{UserID: "u1", Amount: 100},
-			{UserID: "u2", Amount: 150},
+			{UserID: "u1", Amount: inr(10000)},
+			{UserID: "u2", Amount: inr(15000)},
 		},
 	}
 
-	_, err := calculateShares(input)
+	_, err := calculateShares(input, DefaultRemainderStrategy)
 	if err == nil {
 		t.Errorf("expected error for invalid exact split sum")
 	}
@@ -42,8 +46,8 @@ func TestCalculateExactSplit_InvalidSum(t *testing.T) {
 
 func TestCalculatePercentageSplit_Success(t *testing.T) {
 	input := ExpenseInput{
-		TotalAmount: 200,
-		SplitType:   SplitPercentage,
+		TotalAmount:  inr(20000),
+		SplitType:    SplitPercentage,
 		Participants: []string{"u1", "u2"},
 		Splits: []SplitInput{
 			{UserID: "u1", Percentage: 50},
@@ -51,20 +55,20 @@ func TestCalculatePercentageSplit_Success(t *testing.T) {
 		},
 	}
 
-	shares, err := calculateShares(input)
+	shares, err := calculateShares(input, DefaultRemainderStrategy)
 	if err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if shares["u1"] != 100 || shares["u2"] != 100 {
+	if shares["u1"] != inr(10000) || shares["u2"] != inr(10000) {
 		t.Errorf("incorrect percentage split result: %v", shares)
 	}
 }
 
 func TestCalculatePercentageSplit_InvalidTotal(t *testing.T) {
 	input := ExpenseInput{
-		TotalAmount: 200,
-		SplitType:   SplitPercentage,
+		TotalAmount:  inr(20000),
+		SplitType:    SplitPercentage,
 		Participants: []string{"u1", "u2"},
 		Splits: []SplitInput{
 			{UserID: "u1", Percentage: 60},
@@ -72,8 +76,236 @@ func TestCalculatePercentageSplit_InvalidTotal(t *testing.T) {
 		},
 	}
 
-	_, err := calculateShares(input)
+	_, err := calculateShares(input, DefaultRemainderStrategy)
 	if err == nil {
 		t.Errorf("expected error when percentage != 100")
 	}
 }
+
+func TestCalculatePercentageSplit_BelowPrecision(t *testing.T) {
+	input := ExpenseInput{
+		TotalAmount:  inr(10000),
+		SplitType:    SplitPercentage,
+		Participants: []string{"u1", "u2"},
+		Splits: []SplitInput{
+			{UserID: "u1", Percentage: 99.99999},
+			{UserID: "u2", Percentage: 0.00001},
+		},
+	}
+
+	_, err := calculateShares(input, RemainderLargest)
+	assertKind(t, err, ErrValidation)
+}
+
+func TestCalculateEqualSplit_LargestRemainder(t *testing.T) {
+	input := ExpenseInput{
+		TotalAmount:  inr(10000),
+		PaidBy:       "u1",
+		SplitType:    SplitEqual,
+		Participants: []string{"u3", "u2", "u1"},
+	}
+
+	shares, err := calculateShares(input, RemainderLargest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Equal remainders are broken by user ID, so u1 gets the extra paisa.
+	if shares["u1"] != inr(3334) || shares["u2"] != inr(3333) || shares["u3"] != inr(3333) {
+		t.Errorf("incorrect equal split result: %v", shares)
+	}
+}
+
+func TestCalculateEqualSplit_PayerFirst(t *testing.T) {
+	input := ExpenseInput{
+		TotalAmount:  inr(10001),
+		PaidBy:       "u3",
+		SplitType:    SplitEqual,
+		Participants: []string{"u1", "u2", "u3"},
+	}
+
+	shares, err := calculateShares(input, RemainderPayerFirst)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if shares["u1"] != inr(3333) || shares["u2"] != inr(3333) || shares["u3"] != inr(3335) {
+		t.Errorf("incorrect equal split result: %v", shares)
+	}
+}
+
+func TestCalculateShares_RejectsDuplicateUsers(t *testing.T) {
+	tests := []ExpenseInput{
+		{SplitType: SplitEqual, Participants: []string{"u1", "u1", "u2"}},
+		{SplitType: SplitExact, Participants: []string{"u1", "u2"}, Splits: []SplitInput{
+			{UserID: "u1", Amount: inr(5000)}, {UserID: "u1", Amount: inr(5000)},
+		}},
+		{SplitType: SplitPercentage, Participants: []string{"u1", "u2"}, Splits: []SplitInput{
+			{UserID: "u1", Percentage: 50}, {UserID: "u1", Percentage: 50},
+		}},
+		{SplitType: SplitShares, Participants: []string{"u1", "u2"}, Splits: []SplitInput{
+			{UserID: "u1", Shares: 1}, {UserID: "u2", Shares: 1}, {UserID: "u1", Shares: 1},
+		}},
+	}
+	for _, input := range tests {
+		input.TotalAmount = inr(10000)
+		input.PaidBy = "u2"
+		if shares, err := calculateShares(input, DefaultRemainderStrategy); err == nil {
+			t.Errorf("%s split with a repeated user: got %v, want an error", input.SplitType, shares)
+		}
+	}
+}
+
+func TestCalculatePercentageSplit_SumsToTotal(t *testing.T) {
+	input := ExpenseInput{
+		TotalAmount:  inr(1000),
+		SplitType:    SplitPercentage,
+		Participants: []string{"u1", "u2", "u3"},
+		Splits: []SplitInput{
+			{UserID: "u1", Percentage: 33.33},
+			{UserID: "u2", Percentage: 33.33},
+			{UserID: "u3", Percentage: 33.34},
+		},
+	}
+
+	shares, err := calculateShares(input, RemainderLargest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if total := sumShares(shares, DefaultCurrency); total != inr(1000) {
+		t.Errorf("shares sum to %v, want 10.00: %v", total, shares)
+	}
+	if shares["u3"] != inr(334) {
+		t.Errorf("incorrect percentage split result: %v", shares)
+	}
+}
+
+func TestCalculateSharesSplit_Weighted(t *testing.T) {
+	input := ExpenseInput{
+		TotalAmount:  inr(10001),
+		SplitType:    SplitShares,
+		Participants: []string{"u1", "u2", "u3"},
+		Splits: []SplitInput{
+			{UserID: "u1", Shares: 2},
+			{UserID: "u2", Shares: 1},
+			{UserID: "u3", Shares: 1},
+		},
+	}
+
+	shares, err := calculateShares(input, RemainderLargest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if shares["u1"] != inr(5001) || shares["u2"] != inr(2500) || shares["u3"] != inr(2500) {
+		t.Errorf("incorrect shares split result: %v", shares)
+	}
+}
+
+func TestCalculateSharesSplit_NonPositiveShares(t *testing.T) {
+	input := ExpenseInput{
+		TotalAmount:  inr(10000),
+		SplitType:    SplitShares,
+		Participants: []string{"u1", "u2"},
+		Splits: []SplitInput{
+			{UserID: "u1", Shares: 1},
+			{UserID: "u2", Shares: 0},
+		},
+	}
+
+	if _, err := calculateShares(input, RemainderLargest); err == nil {
+		t.Errorf("expected error for zero shares")
+	}
+}
+
+func TestCalculateSharesSplit_TooManyShares(t *testing.T) {
+	input := ExpenseInput{
+		TotalAmount:  inr(10000),
+		SplitType:    SplitShares,
+		Participants: []string{"u1", "u2"},
+		Splits: []SplitInput{
+			{UserID: "u1", Shares: 1},
+			{UserID: "u2", Shares: 1e300},
+		},
+	}
+
+	_, err := calculateShares(input, RemainderLargest)
+	assertKind(t, err, ErrValidation)
+}
+
+func TestCalculateAdjustmentSplit(t *testing.T) {
+	// 100 split three ways, but u1 ordered wine for 10 more.
+	input := ExpenseInput{
+		TotalAmount:  inr(10000),
+		SplitType:    SplitAdjustment,
+		Participants: []string{"u1", "u2", "u3"},
+		Splits: []SplitInput{
+			{UserID: "u1", Adjustment: inr(1000)},
+		},
+	}
+
+	shares, err := calculateShares(input, RemainderLargest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if shares["u1"] != inr(4000) || shares["u2"] != inr(3000) || shares["u3"] != inr(3000) {
+		t.Errorf("incorrect adjustment split result: %v", shares)
+	}
+}
+
+func TestCalculateAdjustmentSplit_NegativeShare(t *testing.T) {
+	input := ExpenseInput{
+		TotalAmount:  inr(10000),
+		SplitType:    SplitAdjustment,
+		Participants: []string{"u1", "u2"},
+		Splits: []SplitInput{
+			{UserID: "u1", Adjustment: inr(-15000)},
+		},
+	}
+
+	if _, err := calculateShares(input, RemainderLargest); err == nil {
+		t.Errorf("expected error when an adjustment makes a share negative")
+	}
+}
+
+func TestCalculateItemizedSplit_TaxAndTipProportional(t *testing.T) {
+	input := ExpenseInput{
+		TotalAmount:  inr(6900),
+		SplitType:    SplitItemized,
+		Participants: []string{"u1", "u2", "u3"},
+		Items: []ItemInput{
+			{Name: "pizza", Price: inr(3000), Consumers: []string{"u1", "u2"}},
+			{Name: "wine", Price: inr(2000), Consumers: []string{"u1"}},
+			{Name: "salad", Price: inr(1000), Consumers: []string{"u3"}},
+		},
+		Tax: inr(600),
+		Tip: inr(300),
+	}
+
+	shares, err := calculateShares(input, RemainderLargest)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if shares["u1"] != inr(4025) || shares["u2"] != inr(1725) || shares["u3"] != inr(1150) {
+		t.Errorf("incorrect itemized split result: %v", shares)
+	}
+}
+
+func TestCalculateItemizedSplit_TotalMismatch(t *testing.T) {
+	input := ExpenseInput{
+		TotalAmount:  inr(5000),
+		SplitType:    SplitItemized,
+		Participants: []string{"u1", "u2"},
+		Items: []ItemInput{
+			{Name: "pizza", Price: inr(3000), Consumers: []string{"u1", "u2"}},
+		},
+		Tip: inr(500),
+	}
+
+	if _, err := calculateShares(input, RemainderLargest); err == nil {
+		t.Errorf("expected error when items, tax and tip do not match the total")
+	}
+}
@@ -0,0 +1,153 @@
+package ledger
+
+import (
+	"context"
+	"time"
+)
+
+// Store is where a Ledger keeps users, groups, expenses, settlements and
+// balances. The ledger's rules (splitting, netting, simplification,
+// settlement checks) live in Ledger; a Store only reads and writes records.
+//
+// PostgresStore, SQLiteStore and MemoryStore are the implementations. The
+// interface's methods are unexported, so stores can only be added to this
+// package.
+type Store interface {
+	storeReader
+
+	// withTx runs fn in one serializable transaction: its writes become
+	// visible together when fn returns nil and are discarded otherwise.
+	// withTx makes a single attempt; Ledger.withTx retries conflicts.
+	withTx(ctx context.Context, fn func(tx storeTx) error) error
+}
+
+// storeReader holds the reads shared by the store itself and its
+// transactions. Lists are returned in a stable order.
+type storeReader interface {
+	// validID reports whether id has the form of the store's IDs. A store
+	// keyed by UUIDs rejects other strings, which could never match.
+	validID(id string) bool
+
+	// groupSettings returns the settings of the group that owns a balance
+	// scope, archived or not, or ErrGroupNotFound. The direct scope uses
+	// the defaults.
+	groupSettings(ctx context.Context, groupID string) (groupSettings, error)
+	// allGroupSettings returns the settings of every group by ID.
+	allGroupSettings(ctx context.Context) (map[string]groupSettings, error)
+
+	// users lists the active users.
+	users(ctx context.Context) ([]UserView, error)
+	// user returns an active user, or ErrUserNotFound.
+	user(ctx context.Context, userID string) (UserView, error)
+	// userIDByEmail finds the user, active or deactivated, whose email
+	// matches case-insensitively; ok is false when there is none.
+	userIDByEmail(ctx context.Context, email string) (id string, ok bool, err error)
+	// groups lists the groups that are not archived.
+	groups(ctx context.Context) ([]GroupView, error)
+	// groupMembers lists the group's active members.
+	groupMembers(ctx context.Context, groupID string) ([]UserView, error)
+	// isGroupMember reports whether the user is a member of the group.
+	isGroupMember(ctx context.Context, groupID, userID string) (bool, error)
+
+	// balance returns what from owes to within a scope; ok is false when
+	// no balance is recorded.
+	balance(ctx context.Context, groupID, fromUserID, toUserID string) (amount Money, ok bool, err error)
+	// userBalances lists the balances the user is part of, ordered by
+	// scope (direct first), then from and to.
+	userBalances(ctx context.Context, userID string) ([]BalanceView, error)
+	// scopeBalances lists the balances of one scope ordered by from and to.
+	scopeBalances(ctx context.Context, groupID string) ([]BalanceView, error)
+	// allBalances lists every balance.
+	allBalances(ctx context.Context) ([]BalanceView, error)
+
+	// expense returns an expense with its payers, splits and items, or
+	// ErrExpenseNotFound.
+	expense(ctx context.Context, expenseID string) (ExpenseView, error)
+	// expenseRequestHash returns the fingerprint of the request that
+	// created or last updated the expense; ok is false when the expense
+	// does not exist.
+	expenseRequestHash(ctx context.Context, expenseID string) (hash string, ok bool, err error)
+	// listExpenses returns up to limit expenses matching the filter, newest
+	// first, starting after the given page key. The filter's Cursor and
+	// Limit are ignored.
+	listExpenses(ctx context.Context, filter ExpenseFilter, after *pageKey, limit int) ([]ExpenseView, error)
+	// allExpenseShares returns every expense with only its ID, GroupID,
+	// Payers and Splits filled in, for recomputing balances.
+	allExpenseShares(ctx context.Context) ([]ExpenseView, error)
+
+	// settlement returns a settlement; ok is false when it does not exist.
+	settlement(ctx context.Context, settlementID string) (s SettlementView, ok bool, err error)
+	// listSettlements is listExpenses for settlements.
+	listSettlements(ctx context.Context, filter SettlementFilter, after *pageKey, limit int) ([]SettlementView, error)
+	// allSettlements returns every settlement.
+	allSettlements(ctx context.Context) ([]SettlementView, error)
+}
+
+// storeTx is a Store transaction.
+type storeTx interface {
+	storeReader
+
+	// insertUser stores a new active user.
+	insertUser(ctx context.Context, u UserView) error
+	// updateUser replaces an active user's name and email.
+	updateUser(ctx context.Context, u UserView) error
+	// deactivateUser marks an active user as deactivated. Their records
+	// stay, but users and groupMembers leave them out.
+	deactivateUser(ctx context.Context, userID string) error
+
+	// insertGroup stores a new group with g's name, base currency and
+	// remainder strategy and default settings otherwise.
+	insertGroup(ctx context.Context, g GroupView) error
+	renameGroup(ctx context.Context, groupID, name string) error
+	// archiveGroup marks a group as archived; groups leaves it out.
+	archiveGroup(ctx context.Context, groupID string) error
+	setSimplifyDebts(ctx context.Context, groupID string, enabled bool) error
+	// markSimplified records that a group's balances were simplified.
+	markSimplified(ctx context.Context, groupID string) error
+	// insertGroupMember adds a user to a group; adding a member again
+	// changes nothing.
+	insertGroupMember(ctx context.Context, groupID, userID string) error
+	// deleteGroupMember removes a user from a group, or returns
+	// ErrNotGroupMember.
+	deleteGroupMember(ctx context.Context, groupID, userID string) error
+
+	// putBalance records b.Amount (positive) as what b.FromUserID owes
+	// b.ToUserID in b.GroupID's scope, replacing any previous amount.
+	putBalance(ctx context.Context, b BalanceView) error
+	deleteBalance(ctx context.Context, groupID, fromUserID, toUserID string) error
+	// deleteScopeBalances removes every balance of one scope.
+	deleteScopeBalances(ctx context.Context, groupID string) error
+	deleteAllBalances(ctx context.Context) error
+
+	// lockExpense is expense for an expense about to be changed; stores
+	// that lock rows lock it until the transaction ends.
+	lockExpense(ctx context.Context, expenseID string) (ExpenseView, error)
+	// insertExpense stores a new expense with its payers, splits and
+	// items. CreatedAt is set by the store.
+	insertExpense(ctx context.Context, e ExpenseView, requestHash string) error
+	// updateExpense replaces an existing expense with its payers, splits
+	// and items and the fingerprint of the request, keeping its creation
+	// time and setting UpdatedAt.
+	updateExpense(ctx context.Context, e ExpenseView, requestHash string) error
+	// deleteExpense removes an expense with its payers, splits and items.
+	deleteExpense(ctx context.Context, expenseID string) error
+
+	// insertSettlement stores a new settlement. CreatedAt is set by the
+	// store.
+	insertSettlement(ctx context.Context, s SettlementView) error
+}
+
+// pageKey is the (created_at, id) sort key that cursor pagination resumes
+// after.
+type pageKey struct {
+	createdAt time.Time
+	id        string
+}
+
+var (
+	_ Store   = (*PostgresStore)(nil)
+	_ Store   = (*SQLiteStore)(nil)
+	_ storeTx = sqlQueries{}
+	_ Store   = (*MemoryStore)(nil)
+	_ storeTx = (*memoryState)(nil)
+)
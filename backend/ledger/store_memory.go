@@ -0,0 +1,732 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the ledger in process memory. It is meant for tests and
+// demos: nothing survives a restart.
+//
+// Transactions run one at a time against a private copy of the data, which
+// replaces the shared copy only when the transaction succeeds, so a failed
+// write leaves no trace. Reads outside a transaction see the last committed
+// state and never wait for a writer.
+type MemoryStore struct {
+	txMu  sync.Mutex   // held for the whole of a write transaction
+	mu    sync.RWMutex // guards state
+	state *memoryState
+}
+
+// NewMemoryStore returns an empty store. Add users and groups with AddUser,
+// AddGroup and AddGroupMember.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{state: &memoryState{
+		usersByID:   map[string]memoryUser{},
+		groupsByID:  map[string]memoryGroup{},
+		members:     map[memberKey]struct{}{},
+		balances:    map[balanceKey]BalanceView{},
+		expenses:    map[string]memoryExpense{},
+		settlements: map[string]SettlementView{},
+	}}
+}
+
+// AddUser creates a user. IDs and emails must be unique.
+func (s *MemoryStore) AddUser(id, name, email string) error {
+	return s.update(func(st *memoryState) error {
+		if _, ok := st.usersByID[id]; ok {
+			return fmt.Errorf("user %s already exists", id)
+		}
+		if _, taken, _ := st.userIDByEmail(context.Background(), email); taken {
+			return fmt.Errorf("email %s is already in use", email)
+		}
+		st.usersByID[id] = memoryUser{UserView: UserView{ID: id, Name: name, Email: email}}
+		return nil
+	})
+}
+
+// AddGroup creates a group keeping balances in baseCurrency, or in
+// DefaultCurrency when it is empty.
+func (s *MemoryStore) AddGroup(id, name, baseCurrency string) error {
+	if baseCurrency == "" {
+		baseCurrency = DefaultCurrency
+	}
+	return s.update(func(st *memoryState) error {
+		if _, ok := st.groupsByID[id]; ok {
+			return fmt.Errorf("group %s already exists", id)
+		}
+		settings := defaultGroupSettings
+		settings.baseCurrency = baseCurrency
+		st.groupsByID[id] = memoryGroup{name: name, settings: settings}
+		return nil
+	})
+}
+
+// AddGroupMember adds an existing user to an existing group.
+func (s *MemoryStore) AddGroupMember(groupID, userID string) error {
+	return s.update(func(st *memoryState) error {
+		return st.insertGroupMember(context.Background(), groupID, userID)
+	})
+}
+
+func (s *MemoryStore) withTx(ctx context.Context, fn func(tx storeTx) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.update(func(st *memoryState) error {
+		if err := fn(st); err != nil {
+			return err
+		}
+		// a transaction that outlived its context is not committed
+		return ctx.Err()
+	})
+}
+
+// update runs fn on a copy of the state and commits the copy when fn
+// succeeds.
+func (s *MemoryStore) update(fn func(st *memoryState) error) error {
+	s.txMu.Lock()
+	defer s.txMu.Unlock()
+
+	next := s.snapshot().clone()
+	if err := fn(next); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.state = next
+	s.mu.Unlock()
+	return nil
+}
+
+// snapshot returns the committed state. It is never modified afterwards.
+func (s *MemoryStore) snapshot() *memoryState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.state
+}
+
+func (s *MemoryStore) validID(string) bool { return true }
+
+func (s *MemoryStore) groupSettings(ctx context.Context, groupID string) (groupSettings, error) {
+	return s.snapshot().groupSettings(ctx, groupID)
+}
+
+func (s *MemoryStore) allGroupSettings(ctx context.Context) (map[string]groupSettings, error) {
+	return s.snapshot().allGroupSettings(ctx)
+}
+
+func (s *MemoryStore) users(ctx context.Context) ([]UserView, error) {
+	return s.snapshot().users(ctx)
+}
+
+func (s *MemoryStore) user(ctx context.Context, userID string) (UserView, error) {
+	return s.snapshot().user(ctx, userID)
+}
+
+func (s *MemoryStore) userIDByEmail(ctx context.Context, email string) (string, bool, error) {
+	return s.snapshot().userIDByEmail(ctx, email)
+}
+
+func (s *MemoryStore) groups(ctx context.Context) ([]GroupView, error) {
+	return s.snapshot().groups(ctx)
+}
+
+func (s *MemoryStore) groupMembers(ctx context.Context, groupID string) ([]UserView, error) {
+	return s.snapshot().groupMembers(ctx, groupID)
+}
+
+func (s *MemoryStore) isGroupMember(ctx context.Context, groupID, userID string) (bool, error) {
+	return s.snapshot().isGroupMember(ctx, groupID, userID)
+}
+
+func (s *MemoryStore) balance(ctx context.Context, groupID, fromUserID, toUserID string) (Money, bool, error) {
+	return s.snapshot().balance(ctx, groupID, fromUserID, toUserID)
+}
+
+func (s *MemoryStore) userBalances(ctx context.Context, userID string) ([]BalanceView, error) {
+	return s.snapshot().userBalances(ctx, userID)
+}
+
+func (s *MemoryStore) scopeBalances(ctx context.Context, groupID string) ([]BalanceView, error) {
+	return s.snapshot().scopeBalances(ctx, groupID)
+}
+
+func (s *MemoryStore) allBalances(ctx context.Context) ([]BalanceView, error) {
+	return s.snapshot().allBalances(ctx)
+}
+
+func (s *MemoryStore) expense(ctx context.Context, expenseID string) (ExpenseView, error) {
+	return s.snapshot().expense(ctx, expenseID)
+}
+
+func (s *MemoryStore) expenseRequestHash(ctx context.Context, expenseID string) (string, bool, error) {
+	return s.snapshot().expenseRequestHash(ctx, expenseID)
+}
+
+func (s *MemoryStore) listExpenses(ctx context.Context, filter ExpenseFilter, after *pageKey, limit int) ([]ExpenseView, error) {
+	return s.snapshot().listExpenses(ctx, filter, after, limit)
+}
+
+func (s *MemoryStore) allExpenseShares(ctx context.Context) ([]ExpenseView, error) {
+	return s.snapshot().allExpenseShares(ctx)
+}
+
+func (s *MemoryStore) settlement(ctx context.Context, settlementID string) (SettlementView, bool, error) {
+	return s.snapshot().settlement(ctx, settlementID)
+}
+
+func (s *MemoryStore) listSettlements(ctx context.Context, filter SettlementFilter, after *pageKey, limit int) ([]SettlementView, error) {
+	return s.snapshot().listSettlements(ctx, filter, after, limit)
+}
+
+func (s *MemoryStore) allSettlements(ctx context.Context) ([]SettlementView, error) {
+	return s.snapshot().allSettlements(ctx)
+}
+
+// memoryState is one version of a MemoryStore's data. Inside a transaction
+// it is the transaction's private copy and implements storeTx.
+//
+// Records are stored by value and replaced whole, never modified in place,
+// so clone can share them between versions.
+type memoryState struct {
+	usersByID   map[string]memoryUser
+	groupsByID  map[string]memoryGroup
+	members     map[memberKey]struct{}
+	balances    map[balanceKey]BalanceView
+	expenses    map[string]memoryExpense
+	settlements map[string]SettlementView
+}
+
+type memoryUser struct {
+	UserView
+	deactivated bool
+}
+
+type memoryGroup struct {
+	name     string
+	settings groupSettings // including whether the group is archived
+}
+
+type memberKey struct {
+	groupID string
+	userID  string
+}
+
+type balanceKey struct {
+	groupID    string
+	fromUserID string
+	toUserID   string
+}
+
+type memoryExpense struct {
+	ExpenseView
+	requestHash string
+}
+
+func (st *memoryState) clone() *memoryState {
+	return &memoryState{
+		usersByID:   maps.Clone(st.usersByID),
+		groupsByID:  maps.Clone(st.groupsByID),
+		members:     maps.Clone(st.members),
+		balances:    maps.Clone(st.balances),
+		expenses:    maps.Clone(st.expenses),
+		settlements: maps.Clone(st.settlements),
+	}
+}
+
+func (st *memoryState) validID(string) bool { return true }
+
+func (st *memoryState) groupSettings(_ context.Context, groupID string) (groupSettings, error) {
+	if groupID == DirectScope {
+		return defaultGroupSettings, nil
+	}
+	g, ok := st.groupsByID[groupID]
+	if !ok {
+		return groupSettings{}, ErrGroupNotFound
+	}
+	return g.settings, nil
+}
+
+func (st *memoryState) allGroupSettings(context.Context) (map[string]groupSettings, error) {
+	all := make(map[string]groupSettings, len(st.groupsByID))
+	for id, g := range st.groupsByID {
+		all[id] = g.settings
+	}
+	return all, nil
+}
+
+func (st *memoryState) insertGroup(_ context.Context, g GroupView) error {
+	if _, ok := st.groupsByID[g.ID]; ok {
+		return fmt.Errorf("group %s already exists", g.ID)
+	}
+	settings := defaultGroupSettings
+	settings.baseCurrency = g.BaseCurrency
+	settings.remainderStrategy = g.RemainderStrategy
+	st.groupsByID[g.ID] = memoryGroup{name: g.Name, settings: settings}
+	return nil
+}
+
+func (st *memoryState) renameGroup(_ context.Context, groupID, name string) error {
+	g, ok := st.groupsByID[groupID]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	g.name = name
+	st.groupsByID[groupID] = g
+	return nil
+}
+
+func (st *memoryState) archiveGroup(_ context.Context, groupID string) error {
+	g, ok := st.groupsByID[groupID]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	g.settings.archived = true
+	st.groupsByID[groupID] = g
+	return nil
+}
+
+func (st *memoryState) setSimplifyDebts(_ context.Context, groupID string, enabled bool) error {
+	g, ok := st.groupsByID[groupID]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	g.settings.simplifyDebts = enabled
+	st.groupsByID[groupID] = g
+	return nil
+}
+
+func (st *memoryState) markSimplified(_ context.Context, groupID string) error {
+	g, ok := st.groupsByID[groupID]
+	if !ok {
+		return ErrGroupNotFound
+	}
+	g.settings.simplified = true
+	st.groupsByID[groupID] = g
+	return nil
+}
+
+func (st *memoryState) users(context.Context) ([]UserView, error) {
+	users := []UserView{}
+	for _, u := range st.usersByID {
+		if !u.deactivated {
+			users = append(users, u.UserView)
+		}
+	}
+	sortUsers(users)
+	return users, nil
+}
+
+func (st *memoryState) user(_ context.Context, userID string) (UserView, error) {
+	u, ok := st.usersByID[userID]
+	if !ok || u.deactivated {
+		return UserView{}, ErrUserNotFound
+	}
+	return u.UserView, nil
+}
+
+func (st *memoryState) userIDByEmail(_ context.Context, email string) (string, bool, error) {
+	for id, u := range st.usersByID {
+		if strings.EqualFold(u.Email, email) {
+			return id, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func (st *memoryState) insertUser(_ context.Context, u UserView) error {
+	if _, ok := st.usersByID[u.ID]; ok {
+		return fmt.Errorf("user %s already exists", u.ID)
+	}
+	st.usersByID[u.ID] = memoryUser{UserView: u}
+	return nil
+}
+
+func (st *memoryState) updateUser(_ context.Context, u UserView) error {
+	existing, ok := st.usersByID[u.ID]
+	if !ok || existing.deactivated {
+		return ErrUserNotFound
+	}
+	st.usersByID[u.ID] = memoryUser{UserView: u}
+	return nil
+}
+
+func (st *memoryState) deactivateUser(_ context.Context, userID string) error {
+	u, ok := st.usersByID[userID]
+	if !ok || u.deactivated {
+		return ErrUserNotFound
+	}
+	u.deactivated = true
+	st.usersByID[userID] = u
+	return nil
+}
+
+func (st *memoryState) groups(context.Context) ([]GroupView, error) {
+	groups := []GroupView{}
+	for id, g := range st.groupsByID {
+		if g.settings.archived {
+			continue
+		}
+		groups = append(groups, GroupView{
+			ID:                id,
+			Name:              g.name,
+			BaseCurrency:      g.settings.baseCurrency,
+			SimplifyDebts:     g.settings.simplifyDebts,
+			RemainderStrategy: g.settings.remainderStrategy,
+		})
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if groups[i].Name != groups[j].Name {
+			return groups[i].Name < groups[j].Name
+		}
+		return groups[i].ID < groups[j].ID
+	})
+	return groups, nil
+}
+
+func (st *memoryState) groupMembers(_ context.Context, groupID string) ([]UserView, error) {
+	users := []UserView{}
+	for key := range st.members {
+		if u := st.usersByID[key.userID]; key.groupID == groupID && !u.deactivated {
+			users = append(users, u.UserView)
+		}
+	}
+	sortUsers(users)
+	return users, nil
+}
+
+func (st *memoryState) isGroupMember(_ context.Context, groupID, userID string) (bool, error) {
+	_, ok := st.members[memberKey{groupID, userID}]
+	return ok, nil
+}
+
+func (st *memoryState) insertGroupMember(_ context.Context, groupID, userID string) error {
+	if _, ok := st.groupsByID[groupID]; !ok {
+		return ErrGroupNotFound
+	}
+	if _, ok := st.usersByID[userID]; !ok {
+		return ErrUserNotFound
+	}
+	st.members[memberKey{groupID, userID}] = struct{}{}
+	return nil
+}
+
+func (st *memoryState) deleteGroupMember(_ context.Context, groupID, userID string) error {
+	key := memberKey{groupID, userID}
+	if _, ok := st.members[key]; !ok {
+		return ErrNotGroupMember
+	}
+	delete(st.members, key)
+	return nil
+}
+
+// sortUsers orders users by name, like the SQL stores.
+func sortUsers(users []UserView) {
+	sort.Slice(users, func(i, j int) bool {
+		if users[i].Name != users[j].Name {
+			return users[i].Name < users[j].Name
+		}
+		return users[i].ID < users[j].ID
+	})
+}
+
+func (st *memoryState) balance(_ context.Context, groupID, fromUserID, toUserID string) (Money, bool, error) {
+	b, ok := st.balances[balanceKey{groupID, fromUserID, toUserID}]
+	return b.Amount, ok, nil
+}
+
+func (st *memoryState) userBalances(_ context.Context, userID string) ([]BalanceView, error) {
+	return st.filterBalances(func(b BalanceView) bool {
+		return b.FromUserID == userID || b.ToUserID == userID
+	}), nil
+}
+
+func (st *memoryState) scopeBalances(_ context.Context, groupID string) ([]BalanceView, error) {
+	return st.filterBalances(func(b BalanceView) bool {
+		return b.GroupID == groupID
+	}), nil
+}
+
+func (st *memoryState) allBalances(context.Context) ([]BalanceView, error) {
+	return st.filterBalances(func(BalanceView) bool { return true }), nil
+}
+
+// filterBalances returns the matching balances ordered by scope (direct
+// first), from and to.
+func (st *memoryState) filterBalances(match func(BalanceView) bool) []BalanceView {
+	balances := []BalanceView{}
+	for _, b := range st.balances {
+		if match(b) {
+			balances = append(balances, b)
+		}
+	}
+	sort.Slice(balances, func(i, j int) bool {
+		a, b := balances[i], balances[j]
+		if a.GroupID != b.GroupID {
+			return a.GroupID < b.GroupID
+		}
+		if a.FromUserID != b.FromUserID {
+			return a.FromUserID < b.FromUserID
+		}
+		return a.ToUserID < b.ToUserID
+	})
+	return balances
+}
+
+func (st *memoryState) putBalance(_ context.Context, b BalanceView) error {
+	if err := st.checkScope(b.GroupID, b.FromUserID, b.ToUserID); err != nil {
+		return err
+	}
+	if !b.Amount.IsPositive() {
+		return errors.New("balance amount must be positive")
+	}
+	st.balances[balanceKey{b.GroupID, b.FromUserID, b.ToUserID}] = b
+	return nil
+}
+
+func (st *memoryState) deleteBalance(_ context.Context, groupID, fromUserID, toUserID string) error {
+	delete(st.balances, balanceKey{groupID, fromUserID, toUserID})
+	return nil
+}
+
+func (st *memoryState) deleteScopeBalances(_ context.Context, groupID string) error {
+	for key := range st.balances {
+		if key.groupID == groupID {
+			delete(st.balances, key)
+		}
+	}
+	return nil
+}
+
+func (st *memoryState) deleteAllBalances(context.Context) error {
+	clear(st.balances)
+	return nil
+}
+
+// checkScope stands in for the foreign keys of the SQL schema: the group,
+// if any, and every user must exist.
+func (st *memoryState) checkScope(groupID string, userIDs ...string) error {
+	if groupID != DirectScope {
+		if _, ok := st.groupsByID[groupID]; !ok {
+			return ErrGroupNotFound
+		}
+	}
+	for _, userID := range userIDs {
+		if _, ok := st.usersByID[userID]; !ok {
+			return fmt.Errorf("user %s not found", userID)
+		}
+	}
+	return nil
+}
+
+func (st *memoryState) expense(_ context.Context, expenseID string) (ExpenseView, error) {
+	e, ok := st.expenses[expenseID]
+	if !ok {
+		return ExpenseView{}, ErrExpenseNotFound
+	}
+	return e.ExpenseView, nil
+}
+
+func (st *memoryState) lockExpense(ctx context.Context, expenseID string) (ExpenseView, error) {
+	// transactions already run one at a time
+	return st.expense(ctx, expenseID)
+}
+
+func (st *memoryState) expenseRequestHash(_ context.Context, expenseID string) (string, bool, error) {
+	e, ok := st.expenses[expenseID]
+	return e.requestHash, ok, nil
+}
+
+func (st *memoryState) listExpenses(_ context.Context, filter ExpenseFilter, after *pageKey, limit int) ([]ExpenseView, error) {
+	expenses := []ExpenseView{}
+	for _, e := range st.expenses {
+		if filter.GroupID != "" && e.GroupID != filter.GroupID {
+			continue
+		}
+		if filter.PaidBy != "" && !hasPayer(e.ExpenseView, filter.PaidBy) {
+			continue
+		}
+		if filter.ParticipantID != "" && !hasSplit(e.ExpenseView, filter.ParticipantID) {
+			continue
+		}
+		if !inPage(e.CreatedAt, e.ID, filter.From, filter.To, after) {
+			continue
+		}
+
+		// lists carry the expense row only, like the SQL stores
+		row := e.ExpenseView
+		row.Payers, row.Splits, row.Items = nil, nil, nil
+		expenses = append(expenses, row)
+	}
+
+	sort.Slice(expenses, func(i, j int) bool {
+		return newerFirst(expenses[i].CreatedAt, expenses[i].ID, expenses[j].CreatedAt, expenses[j].ID)
+	})
+	return expenses[:min(limit, len(expenses))], nil
+}
+
+func hasPayer(e ExpenseView, userID string) bool {
+	for _, p := range e.Payers {
+		if p.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+func hasSplit(e ExpenseView, userID string) bool {
+	for _, s := range e.Splits {
+		if s.UserID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// inPage reports whether a row falls inside the [from, to) time window and
+// sorts after the page key.
+func inPage(createdAt time.Time, id string, from, to time.Time, after *pageKey) bool {
+	if !from.IsZero() && createdAt.Before(from) {
+		return false
+	}
+	if !to.IsZero() && !createdAt.Before(to) {
+		return false
+	}
+	return after == nil || newerFirst(after.createdAt, after.id, createdAt, id)
+}
+
+// newerFirst orders rows by (created_at, id) descending.
+func newerFirst(aCreatedAt time.Time, aID string, bCreatedAt time.Time, bID string) bool {
+	if !aCreatedAt.Equal(bCreatedAt) {
+		return aCreatedAt.After(bCreatedAt)
+	}
+	return aID > bID
+}
+
+func (st *memoryState) allExpenseShares(context.Context) ([]ExpenseView, error) {
+	expenses := []ExpenseView{}
+	for _, e := range st.expenses {
+		expenses = append(expenses, ExpenseView{
+			ID:           e.ID,
+			GroupID:      e.GroupID,
+			BaseCurrency: e.BaseCurrency,
+			Payers:       e.Payers,
+			Splits:       e.Splits,
+		})
+	}
+	sort.Slice(expenses, func(i, j int) bool { return expenses[i].ID < expenses[j].ID })
+	return expenses, nil
+}
+
+func (st *memoryState) insertExpense(_ context.Context, e ExpenseView, requestHash string) error {
+	if _, ok := st.expenses[e.ID]; ok {
+		return fmt.Errorf("expense %s already exists", e.ID)
+	}
+	if err := st.checkExpense(e); err != nil {
+		return err
+	}
+	e.CreatedAt = time.Now()
+	e.UpdatedAt = nil
+	st.expenses[e.ID] = memoryExpense{ExpenseView: e, requestHash: requestHash}
+	return nil
+}
+
+func (st *memoryState) updateExpense(_ context.Context, e ExpenseView, requestHash string) error {
+	old, ok := st.expenses[e.ID]
+	if !ok {
+		return ErrExpenseNotFound
+	}
+	if err := st.checkExpense(e); err != nil {
+		return err
+	}
+	now := time.Now()
+	e.CreatedAt = old.CreatedAt
+	e.UpdatedAt = &now
+	st.expenses[e.ID] = memoryExpense{ExpenseView: e, requestHash: requestHash}
+	return nil
+}
+
+// checkExpense checks that everyone the expense names exists.
+func (st *memoryState) checkExpense(e ExpenseView) error {
+	userIDs := []string{e.PaidBy}
+	for _, p := range e.Payers {
+		userIDs = append(userIDs, p.UserID)
+	}
+	for _, s := range e.Splits {
+		userIDs = append(userIDs, s.UserID)
+	}
+	for _, item := range e.Items {
+		userIDs = append(userIDs, item.Consumers...)
+	}
+	return st.checkScope(e.GroupID, userIDs...)
+}
+
+func (st *memoryState) deleteExpense(_ context.Context, expenseID string) error {
+	delete(st.expenses, expenseID)
+	return nil
+}
+
+func (st *memoryState) settlement(_ context.Context, settlementID string) (SettlementView, bool, error) {
+	s, ok := st.settlements[settlementID]
+	return s, ok, nil
+}
+
+func (st *memoryState) listSettlements(_ context.Context, filter SettlementFilter, after *pageKey, limit int) ([]SettlementView, error) {
+	settlements := []SettlementView{}
+	for _, s := range st.settlements {
+		if filter.GroupID != "" && s.GroupID != filter.GroupID {
+			continue
+		}
+		switch {
+		case filter.CounterpartyID != "":
+			between := (s.FromUserID == filter.UserID && s.ToUserID == filter.CounterpartyID) ||
+				(s.FromUserID == filter.CounterpartyID && s.ToUserID == filter.UserID)
+			if !between {
+				continue
+			}
+		case filter.UserID != "":
+			if s.FromUserID != filter.UserID && s.ToUserID != filter.UserID {
+				continue
+			}
+		}
+		if !inPage(s.CreatedAt, s.ID, filter.From, filter.To, after) {
+			continue
+		}
+		settlements = append(settlements, s)
+	}
+
+	sort.Slice(settlements, func(i, j int) bool {
+		return newerFirst(settlements[i].CreatedAt, settlements[i].ID, settlements[j].CreatedAt, settlements[j].ID)
+	})
+	return settlements[:min(limit, len(settlements))], nil
+}
+
+func (st *memoryState) allSettlements(context.Context) ([]SettlementView, error) {
+	settlements := []SettlementView{}
+	for _, s := range st.settlements {
+		settlements = append(settlements, s)
+	}
+	sort.Slice(settlements, func(i, j int) bool {
+		return !newerFirst(settlements[i].CreatedAt, settlements[i].ID, settlements[j].CreatedAt, settlements[j].ID)
+	})
+	return settlements, nil
+}
+
+func (st *memoryState) insertSettlement(_ context.Context, s SettlementView) error {
+	if _, ok := st.settlements[s.ID]; ok {
+		return fmt.Errorf("settlement %s already exists", s.ID)
+	}
+	if err := st.checkScope(s.GroupID, s.FromUserID, s.ToUserID); err != nil {
+		return err
+	}
+	s.CreatedAt = time.Now()
+	st.settlements[s.ID] = s
+	return nil
+}
@@ -0,0 +1,54 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// PostgresStore keeps the ledger in PostgreSQL, using the schema in
+// db/migrations/postgres.
+type PostgresStore struct {
+	sqlQueries
+	db *sql.DB
+}
+
+// NewPostgresStore returns a store that runs on db.
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{sqlQueries: sqlQueries{q: db, d: postgresDialect}, db: db}
+}
+
+func (s *PostgresStore) withTx(ctx context.Context, fn func(tx storeTx) error) error {
+	tx, err := s.db.BeginTx(
+		ctx,
+		&sql.TxOptions{
+			Isolation: sql.LevelSerializable,
+		},
+	)
+	if err != nil {
+		return err
+	}
+	// Ensuring rollback in case of any error or issue uprising
+	defer tx.Rollback()
+
+	if err := fn(sqlQueries{q: tx, d: postgresDialect}); err != nil {
+		return err
+	}
+	// if passing all the cases then commit
+	return tx.Commit()
+}
+
+var postgresDialect = &sqlDialect{
+	// the TIMESTAMP columns hold UTC, whatever the session's time zone
+	now:      "(NOW() AT TIME ZONE 'UTC')",
+	lockRows: "FOR UPDATE",
+	// balances has UNIQUE NULLS NOT DISTINCT on these columns, so the
+	// direct scope's NULL group_id conflicts too
+	balanceKey: "(group_id, from_user_id, to_user_id)",
+	// the columns are TIMESTAMP without a zone, which pgx fills with the
+	// wall-clock time as given, so convert to UTC first
+	timeArg: func(t time.Time) any { return t.UTC() },
+	validID: func(id string) bool { return uuid.Validate(id) == nil },
+}
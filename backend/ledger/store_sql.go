@@ -0,0 +1,951 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// sqlConn is satisfied by both *sql.DB and *sql.Tx.
+type sqlConn interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// sqlQueries runs the ledger's SQL, either directly on the database or
+// inside a transaction. PostgresStore and SQLiteStore share it; the few
+// statements that differ between the two come from the dialect.
+type sqlQueries struct {
+	q sqlConn
+	d *sqlDialect
+}
+
+// sqlDialect holds what differs between the SQL databases the ledger runs
+// on.
+type sqlDialect struct {
+	// now is the SQL expression for the current time.
+	now string
+	// lockRows is appended to a SELECT to lock the rows it reads until the
+	// transaction ends; empty where transactions lock the whole database.
+	lockRows string
+	// balanceKey is the ON CONFLICT target matching the unique index on
+	// balances.
+	balanceKey string
+	// timeArg converts a time for comparison with a timestamp column.
+	timeArg func(t time.Time) any
+	// validID reports whether id can be compared with an ID column. Where
+	// IDs are UUIDs, any other string would be a query error rather than
+	// no match, so lookups answer "not found" without querying.
+	validID func(id string) bool
+}
+
+// scopeParam maps a balance scope to its group_id column value; the direct
+// scope is stored as NULL.
+func scopeParam(groupID string) any {
+	if groupID == DirectScope {
+		return nil
+	}
+	return groupID
+}
+
+func (p sqlQueries) validID(id string) bool { return p.d.validID(id) }
+
+func (p sqlQueries) groupSettings(ctx context.Context, groupID string) (groupSettings, error) {
+	if groupID == DirectScope {
+		return defaultGroupSettings, nil
+	}
+	if !p.d.validID(groupID) {
+		return groupSettings{}, ErrGroupNotFound
+	}
+
+	var settings groupSettings
+	err := p.q.QueryRowContext(ctx, `
+		SELECT base_currency, remainder_strategy, simplify_debts, balances_simplified, archived_at IS NOT NULL
+		FROM groups
+		WHERE id = $1
+	`, groupID).Scan(&settings.baseCurrency, &settings.remainderStrategy, &settings.simplifyDebts, &settings.simplified, &settings.archived)
+
+	if err == sql.ErrNoRows {
+		return groupSettings{}, ErrGroupNotFound
+	}
+	if err != nil {
+		return groupSettings{}, err
+	}
+	if !settings.remainderStrategy.valid() {
+		settings.remainderStrategy = DefaultRemainderStrategy
+	}
+
+	return settings, nil
+}
+
+func (p sqlQueries) allGroupSettings(ctx context.Context) (map[string]groupSettings, error) {
+	rows, err := p.q.QueryContext(ctx, `
+		SELECT id, base_currency, remainder_strategy, simplify_debts, balances_simplified, archived_at IS NOT NULL
+		FROM groups
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	all := map[string]groupSettings{}
+	for rows.Next() {
+		var groupID string
+		var settings groupSettings
+		if err := rows.Scan(&groupID, &settings.baseCurrency, &settings.remainderStrategy, &settings.simplifyDebts, &settings.simplified, &settings.archived); err != nil {
+			return nil, err
+		}
+		if !settings.remainderStrategy.valid() {
+			settings.remainderStrategy = DefaultRemainderStrategy
+		}
+		all[groupID] = settings
+	}
+	return all, rows.Err()
+}
+
+func (p sqlQueries) insertGroup(ctx context.Context, g GroupView) error {
+	_, err := p.q.ExecContext(ctx, `
+		INSERT INTO groups (id, name, base_currency, remainder_strategy)
+		VALUES ($1, $2, $3, $4)
+	`, g.ID, g.Name, g.BaseCurrency, g.RemainderStrategy)
+	return err
+}
+
+func (p sqlQueries) renameGroup(ctx context.Context, groupID, name string) error {
+	result, err := p.q.ExecContext(ctx, `
+		UPDATE groups
+		SET name = $1
+		WHERE id = $2
+	`, name, groupID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+func (p sqlQueries) archiveGroup(ctx context.Context, groupID string) error {
+	result, err := p.q.ExecContext(ctx, `
+		UPDATE groups
+		SET archived_at = COALESCE(archived_at, `+p.d.now+`)
+		WHERE id = $1
+	`, groupID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+func (p sqlQueries) setSimplifyDebts(ctx context.Context, groupID string, enabled bool) error {
+	result, err := p.q.ExecContext(ctx, `
+		UPDATE groups
+		SET simplify_debts = $1
+		WHERE id = $2
+	`, enabled, groupID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+func (p sqlQueries) markSimplified(ctx context.Context, groupID string) error {
+	result, err := p.q.ExecContext(ctx, `
+		UPDATE groups
+		SET balances_simplified = TRUE
+		WHERE id = $1
+	`, groupID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrGroupNotFound
+	}
+	return nil
+}
+
+func (p sqlQueries) users(ctx context.Context) ([]UserView, error) {
+	return p.queryUsers(ctx, `
+		SELECT id, name, email
+		FROM users
+		WHERE deactivated_at IS NULL
+		ORDER BY name
+	`)
+}
+
+func (p sqlQueries) user(ctx context.Context, userID string) (UserView, error) {
+	if !p.d.validID(userID) {
+		return UserView{}, ErrUserNotFound
+	}
+
+	var u UserView
+	err := p.q.QueryRowContext(ctx, `
+		SELECT id, name, email
+		FROM users
+		WHERE id = $1 AND deactivated_at IS NULL
+	`, userID).Scan(&u.ID, &u.Name, &u.Email)
+
+	if err == sql.ErrNoRows {
+		return UserView{}, ErrUserNotFound
+	}
+	return u, err
+}
+
+func (p sqlQueries) userIDByEmail(ctx context.Context, email string) (string, bool, error) {
+	var id string
+	err := p.q.QueryRowContext(ctx, `
+		SELECT id FROM users WHERE LOWER(email) = LOWER($1)
+	`, email).Scan(&id)
+
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return id, true, nil
+}
+
+func (p sqlQueries) insertUser(ctx context.Context, u UserView) error {
+	_, err := p.q.ExecContext(ctx, `
+		INSERT INTO users (id, name, email)
+		VALUES ($1, $2, $3)
+	`, u.ID, u.Name, u.Email)
+	return err
+}
+
+func (p sqlQueries) updateUser(ctx context.Context, u UserView) error {
+	result, err := p.q.ExecContext(ctx, `
+		UPDATE users
+		SET name = $2, email = $3
+		WHERE id = $1 AND deactivated_at IS NULL
+	`, u.ID, u.Name, u.Email)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+func (p sqlQueries) deactivateUser(ctx context.Context, userID string) error {
+	result, err := p.q.ExecContext(ctx, `
+		UPDATE users
+		SET deactivated_at = `+p.d.now+`
+		WHERE id = $1 AND deactivated_at IS NULL
+	`, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrUserNotFound
+	}
+	return nil
+}
+
+// queryUsers runs a query selecting id, name and email.
+func (p sqlQueries) queryUsers(ctx context.Context, query string, args ...any) ([]UserView, error) {
+	rows, err := p.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []UserView{}
+	for rows.Next() {
+		var u UserView
+		if err := rows.Scan(&u.ID, &u.Name, &u.Email); err != nil {
+			return nil, err
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
+
+func (p sqlQueries) groups(ctx context.Context) ([]GroupView, error) {
+	rows, err := p.q.QueryContext(ctx, `
+		SELECT id, name, base_currency, simplify_debts, remainder_strategy
+		FROM groups
+		WHERE archived_at IS NULL
+		ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	groups := []GroupView{}
+	for rows.Next() {
+		var g GroupView
+		if err := rows.Scan(&g.ID, &g.Name, &g.BaseCurrency, &g.SimplifyDebts, &g.RemainderStrategy); err != nil {
+			return nil, err
+		}
+		if !g.RemainderStrategy.valid() {
+			g.RemainderStrategy = DefaultRemainderStrategy
+		}
+		groups = append(groups, g)
+	}
+	return groups, rows.Err()
+}
+
+func (p sqlQueries) groupMembers(ctx context.Context, groupID string) ([]UserView, error) {
+	return p.queryUsers(ctx, `
+		SELECT u.id, u.name, u.email
+		FROM users u
+		JOIN group_members gm ON gm.user_id = u.id
+		WHERE gm.group_id = $1 AND u.deactivated_at IS NULL
+		ORDER BY u.name
+	`, groupID)
+}
+
+func (p sqlQueries) isGroupMember(ctx context.Context, groupID, userID string) (bool, error) {
+	if !p.d.validID(groupID) || !p.d.validID(userID) {
+		return false, nil
+	}
+
+	var member bool
+	err := p.q.QueryRowContext(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM group_members WHERE group_id = $1 AND user_id = $2
+		)
+	`, groupID, userID).Scan(&member)
+	return member, err
+}
+
+func (p sqlQueries) insertGroupMember(ctx context.Context, groupID, userID string) error {
+	_, err := p.q.ExecContext(ctx, `
+		INSERT INTO group_members (group_id, user_id)
+		VALUES ($1, $2)
+		ON CONFLICT DO NOTHING
+	`, groupID, userID)
+	return err
+}
+
+func (p sqlQueries) deleteGroupMember(ctx context.Context, groupID, userID string) error {
+	result, err := p.q.ExecContext(ctx, `
+		DELETE FROM group_members
+		WHERE group_id = $1 AND user_id = $2
+	`, groupID, userID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotGroupMember
+	}
+	return nil
+}
+
+func (p sqlQueries) balance(ctx context.Context, groupID, fromUserID, toUserID string) (Money, bool, error) {
+	var amount Money
+	err := p.q.QueryRowContext(ctx, `
+		SELECT amount, currency
+		FROM balances
+		WHERE group_id IS NOT DISTINCT FROM $1
+		  AND from_user_id = $2 AND to_user_id = $3
+	`, scopeParam(groupID), fromUserID, toUserID).Scan(&amount, &amount.Currency)
+
+	if err == sql.ErrNoRows {
+		return Money{}, false, nil
+	}
+	if err != nil {
+		return Money{}, false, err
+	}
+	return amount, true, nil
+}
+
+func (p sqlQueries) userBalances(ctx context.Context, userID string) ([]BalanceView, error) {
+	return p.queryBalances(ctx, `
+		SELECT group_id, from_user_id, to_user_id, amount, currency
+		FROM balances
+		WHERE from_user_id = $1 OR to_user_id = $1
+		ORDER BY group_id NULLS FIRST, from_user_id, to_user_id
+	`, userID)
+}
+
+func (p sqlQueries) scopeBalances(ctx context.Context, groupID string) ([]BalanceView, error) {
+	return p.queryBalances(ctx, `
+		SELECT group_id, from_user_id, to_user_id, amount, currency
+		FROM balances
+		WHERE group_id IS NOT DISTINCT FROM $1
+		ORDER BY from_user_id, to_user_id
+	`, scopeParam(groupID))
+}
+
+func (p sqlQueries) allBalances(ctx context.Context) ([]BalanceView, error) {
+	return p.queryBalances(ctx, `
+		SELECT group_id, from_user_id, to_user_id, amount, currency
+		FROM balances
+		ORDER BY group_id NULLS FIRST, from_user_id, to_user_id
+	`)
+}
+
+// queryBalances scans (group_id, from, to, amount, currency) rows.
+func (p sqlQueries) queryBalances(ctx context.Context, query string, args ...any) ([]BalanceView, error) {
+	rows, err := p.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	balances := []BalanceView{}
+	for rows.Next() {
+		var groupID sql.NullString
+		var b BalanceView
+		if err := rows.Scan(&groupID, &b.FromUserID, &b.ToUserID, &b.Amount, &b.Currency); err != nil {
+			return nil, err
+		}
+		b.GroupID = groupID.String
+		b.Amount.Currency = b.Currency
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+func (p sqlQueries) putBalance(ctx context.Context, b BalanceView) error {
+	_, err := p.q.ExecContext(ctx, `
+		INSERT INTO balances (group_id, from_user_id, to_user_id, amount, currency)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT `+p.d.balanceKey+`
+		DO UPDATE SET amount = EXCLUDED.amount, currency = EXCLUDED.currency
+	`, scopeParam(b.GroupID), b.FromUserID, b.ToUserID, b.Amount, b.Currency)
+	return err
+}
+
+func (p sqlQueries) deleteBalance(ctx context.Context, groupID, fromUserID, toUserID string) error {
+	_, err := p.q.ExecContext(ctx, `
+		DELETE FROM balances
+		WHERE group_id IS NOT DISTINCT FROM $1
+		  AND from_user_id = $2 AND to_user_id = $3
+	`, scopeParam(groupID), fromUserID, toUserID)
+	return err
+}
+
+func (p sqlQueries) deleteScopeBalances(ctx context.Context, groupID string) error {
+	_, err := p.q.ExecContext(ctx, `
+		DELETE FROM balances
+		WHERE group_id IS NOT DISTINCT FROM $1
+	`, scopeParam(groupID))
+	return err
+}
+
+func (p sqlQueries) deleteAllBalances(ctx context.Context) error {
+	_, err := p.q.ExecContext(ctx, `DELETE FROM balances`)
+	return err
+}
+
+// expenseColumns is the SELECT list scanned by scanExpense.
+const expenseColumns = `
+	e.id, e.group_id, e.paid_by, e.amount, e.currency, e.exchange_rate,
+	e.base_amount, COALESCE(g.base_currency, $1), e.split_type,
+	e.tax, e.tip, COALESCE(e.description, ''), e.created_at, e.updated_at`
+
+func scanExpense(row interface{ Scan(...any) error }) (ExpenseView, error) {
+	var e ExpenseView
+	var groupID sql.NullString
+	var updatedAt sql.NullTime
+	err := row.Scan(
+		&e.ID, &groupID, &e.PaidBy, &e.Amount, &e.Currency, &e.ExchangeRate,
+		&e.BaseAmount, &e.BaseCurrency, &e.SplitType,
+		&e.Tax, &e.Tip, &e.Description, &e.CreatedAt, &updatedAt,
+	)
+	if err != nil {
+		return ExpenseView{}, err
+	}
+
+	e.GroupID = groupID.String
+	e.Amount.Currency = e.Currency
+	e.BaseAmount.Currency = e.BaseCurrency
+	e.Tax.Currency = e.Currency
+	e.Tip.Currency = e.Currency
+	if updatedAt.Valid {
+		e.UpdatedAt = &updatedAt.Time
+	}
+	return e, nil
+}
+
+func (p sqlQueries) expense(ctx context.Context, expenseID string) (ExpenseView, error) {
+	if !p.d.validID(expenseID) {
+		return ExpenseView{}, ErrExpenseNotFound
+	}
+	e, err := scanExpense(p.q.QueryRowContext(ctx, `SELECT `+expenseColumns+`
+		FROM expenses e
+		LEFT JOIN groups g ON g.id = e.group_id
+		WHERE e.id = $2
+	`, DefaultCurrency, expenseID))
+	if err == sql.ErrNoRows {
+		return ExpenseView{}, ErrExpenseNotFound
+	}
+	if err != nil {
+		return ExpenseView{}, err
+	}
+
+	rows, err := p.q.QueryContext(ctx, `
+		SELECT user_id, amount, base_amount
+		FROM expense_payers
+		WHERE expense_id = $1
+		ORDER BY user_id
+	`, expenseID)
+	if err != nil {
+		return ExpenseView{}, err
+	}
+	defer rows.Close()
+
+	e.Payers = []ExpensePayerView{}
+	for rows.Next() {
+		payer := ExpensePayerView{
+			Amount:     Money{Currency: e.Currency},
+			BaseAmount: Money{Currency: e.BaseCurrency},
+		}
+		if err := rows.Scan(&payer.UserID, &payer.Amount, &payer.BaseAmount); err != nil {
+			return ExpenseView{}, err
+		}
+		e.Payers = append(e.Payers, payer)
+	}
+	if err := rows.Err(); err != nil {
+		return ExpenseView{}, err
+	}
+
+	rows, err = p.q.QueryContext(ctx, `
+		SELECT user_id, amount, base_amount
+		FROM expense_splits
+		WHERE expense_id = $1
+		ORDER BY user_id
+	`, expenseID)
+	if err != nil {
+		return ExpenseView{}, err
+	}
+	defer rows.Close()
+
+	e.Splits = []ExpenseSplitView{}
+	for rows.Next() {
+		s := ExpenseSplitView{
+			Amount:     Money{Currency: e.Currency},
+			BaseAmount: Money{Currency: e.BaseCurrency},
+		}
+		if err := rows.Scan(&s.UserID, &s.Amount, &s.BaseAmount); err != nil {
+			return ExpenseView{}, err
+		}
+		e.Splits = append(e.Splits, s)
+	}
+	if err := rows.Err(); err != nil {
+		return ExpenseView{}, err
+	}
+
+	rows, err = p.q.QueryContext(ctx, `
+		SELECT i.position, i.name, i.amount, c.user_id
+		FROM expense_items i
+		LEFT JOIN expense_item_consumers c
+			ON c.expense_id = i.expense_id AND c.position = i.position
+		WHERE i.expense_id = $1
+		ORDER BY i.position, c.user_id
+	`, expenseID)
+	if err != nil {
+		return ExpenseView{}, err
+	}
+	defer rows.Close()
+
+	last := -1
+	for rows.Next() {
+		var position int
+		var consumer sql.NullString
+		item := ExpenseItemView{Amount: Money{Currency: e.Currency}}
+		if err := rows.Scan(&position, &item.Name, &item.Amount, &consumer); err != nil {
+			return ExpenseView{}, err
+		}
+		if position != last {
+			item.Consumers = []string{}
+			e.Items = append(e.Items, item)
+			last = position
+		}
+		if consumer.Valid {
+			current := &e.Items[len(e.Items)-1]
+			current.Consumers = append(current.Consumers, consumer.String)
+		}
+	}
+	return e, rows.Err()
+}
+
+func (p sqlQueries) lockExpense(ctx context.Context, expenseID string) (ExpenseView, error) {
+	if !p.d.validID(expenseID) {
+		return ExpenseView{}, ErrExpenseNotFound
+	}
+	var locked int
+	err := p.q.QueryRowContext(ctx, `
+		SELECT 1
+		FROM expenses
+		WHERE id = $1
+	`+p.d.lockRows, expenseID).Scan(&locked)
+
+	if err == sql.ErrNoRows {
+		return ExpenseView{}, ErrExpenseNotFound
+	}
+	if err != nil {
+		return ExpenseView{}, err
+	}
+	return p.expense(ctx, expenseID)
+}
+
+func (p sqlQueries) expenseRequestHash(ctx context.Context, expenseID string) (string, bool, error) {
+	if !p.d.validID(expenseID) {
+		return "", false, nil
+	}
+	var hash sql.NullString
+	err := p.q.QueryRowContext(ctx, `
+		SELECT request_hash
+		FROM expenses
+		WHERE id = $1
+	`, expenseID).Scan(&hash)
+
+	if err == sql.ErrNoRows {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return hash.String, true, nil
+}
+
+func (p sqlQueries) listExpenses(ctx context.Context, filter ExpenseFilter, after *pageKey, limit int) ([]ExpenseView, error) {
+	query := `SELECT ` + expenseColumns + `
+		FROM expenses e
+		LEFT JOIN groups g ON g.id = e.group_id
+		WHERE TRUE`
+	args := []any{DefaultCurrency}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.GroupID != "" {
+		query += ` AND e.group_id = ` + arg(filter.GroupID)
+	}
+	if filter.PaidBy != "" {
+		query += ` AND EXISTS (
+			SELECT 1 FROM expense_payers p
+			WHERE p.expense_id = e.id AND p.user_id = ` + arg(filter.PaidBy) + `)`
+	}
+	if filter.ParticipantID != "" {
+		query += ` AND EXISTS (
+			SELECT 1 FROM expense_splits s
+			WHERE s.expense_id = e.id AND s.user_id = ` + arg(filter.ParticipantID) + `)`
+	}
+	if !filter.From.IsZero() {
+		query += ` AND e.created_at >= ` + arg(p.d.timeArg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		query += ` AND e.created_at < ` + arg(p.d.timeArg(filter.To))
+	}
+	if after != nil {
+		query += ` AND (e.created_at, e.id) < (` + arg(p.d.timeArg(after.createdAt)) + `, ` + arg(after.id) + `)`
+	}
+	query += ` ORDER BY e.created_at DESC, e.id DESC LIMIT ` + arg(limit)
+
+	rows, err := p.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expenses := []ExpenseView{}
+	for rows.Next() {
+		e, err := scanExpense(rows)
+		if err != nil {
+			return nil, err
+		}
+		expenses = append(expenses, e)
+	}
+	return expenses, rows.Err()
+}
+
+func (p sqlQueries) allExpenseShares(ctx context.Context) ([]ExpenseView, error) {
+	rows, err := p.q.QueryContext(ctx, `
+		SELECT e.id, e.group_id, COALESCE(g.base_currency, $1), a.kind, a.user_id, a.base_amount
+		FROM expenses e
+		LEFT JOIN groups g ON g.id = e.group_id
+		JOIN (
+			SELECT expense_id, 'paid' AS kind, user_id, base_amount FROM expense_payers
+			UNION ALL
+			SELECT expense_id, 'owed' AS kind, user_id, base_amount FROM expense_splits
+		) a ON a.expense_id = e.id
+		ORDER BY e.id, a.kind, a.user_id
+	`, DefaultCurrency)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	expenses := []ExpenseView{}
+	for rows.Next() {
+		var expenseID, baseCurrency, kind, userID string
+		var groupID sql.NullString
+		var amount Money
+		if err := rows.Scan(&expenseID, &groupID, &baseCurrency, &kind, &userID, &amount); err != nil {
+			return nil, err
+		}
+		amount.Currency = baseCurrency
+
+		if len(expenses) == 0 || expenses[len(expenses)-1].ID != expenseID {
+			expenses = append(expenses, ExpenseView{
+				ID:           expenseID,
+				GroupID:      groupID.String,
+				BaseCurrency: baseCurrency,
+			})
+		}
+		e := &expenses[len(expenses)-1]
+		if kind == "paid" {
+			e.Payers = append(e.Payers, ExpensePayerView{UserID: userID, BaseAmount: amount})
+		} else {
+			e.Splits = append(e.Splits, ExpenseSplitView{UserID: userID, BaseAmount: amount})
+		}
+	}
+	return expenses, rows.Err()
+}
+
+func (p sqlQueries) insertExpense(ctx context.Context, e ExpenseView, requestHash string) error {
+	_, err := p.q.ExecContext(ctx,
+		`INSERT INTO expenses (id, group_id, paid_by, amount, currency,
+		                       exchange_rate, base_amount, split_type,
+		                       tax, tip, description, request_hash)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)`,
+		e.ID,
+		scopeParam(e.GroupID),
+		e.PaidBy,
+		e.Amount,
+		e.Currency,
+		e.ExchangeRate,
+		e.BaseAmount,
+		e.SplitType,
+		e.Tax,
+		e.Tip,
+		e.Description,
+		requestHash,
+	)
+	if err != nil {
+		return err
+	}
+	return p.insertExpenseDetails(ctx, e)
+}
+
+func (p sqlQueries) updateExpense(ctx context.Context, e ExpenseView, requestHash string) error {
+	result, err := p.q.ExecContext(ctx,
+		`UPDATE expenses
+		 SET group_id = $2, paid_by = $3, amount = $4, currency = $5,
+		     exchange_rate = $6, base_amount = $7, split_type = $8,
+		     tax = $9, tip = $10, description = $11, request_hash = $12,
+		     updated_at = `+p.d.now+`
+		 WHERE id = $1`,
+		e.ID,
+		scopeParam(e.GroupID),
+		e.PaidBy,
+		e.Amount,
+		e.Currency,
+		e.ExchangeRate,
+		e.BaseAmount,
+		e.SplitType,
+		e.Tax,
+		e.Tip,
+		e.Description,
+		requestHash,
+	)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrExpenseNotFound
+	}
+
+	// item consumers are removed by ON DELETE CASCADE
+	for _, table := range []string{"expense_splits", "expense_payers", "expense_items"} {
+		_, err := p.q.ExecContext(ctx, `DELETE FROM `+table+` WHERE expense_id = $1`, e.ID)
+		if err != nil {
+			return err
+		}
+	}
+	return p.insertExpenseDetails(ctx, e)
+}
+
+// insertExpenseDetails stores one expense_payers row per payer, one
+// expense_splits row per participant and the receipt items, if any.
+func (p sqlQueries) insertExpenseDetails(ctx context.Context, e ExpenseView) error {
+	for _, payer := range e.Payers {
+		_, err := p.q.ExecContext(ctx,
+			`INSERT INTO expense_payers (expense_id, user_id, amount, base_amount)
+			 VALUES ($1, $2, $3, $4)`,
+			e.ID,
+			payer.UserID,
+			payer.Amount,
+			payer.BaseAmount,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, split := range e.Splits {
+		_, err := p.q.ExecContext(ctx,
+			`INSERT INTO expense_splits (expense_id, user_id, amount, base_amount)
+			 VALUES ($1, $2, $3, $4)`,
+			e.ID,
+			split.UserID,
+			split.Amount,
+			split.BaseAmount,
+		)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i, item := range e.Items {
+		_, err := p.q.ExecContext(ctx,
+			`INSERT INTO expense_items (expense_id, position, name, amount)
+			 VALUES ($1, $2, $3, $4)`,
+			e.ID,
+			i,
+			item.Name,
+			item.Amount,
+		)
+		if err != nil {
+			return err
+		}
+		for _, userID := range item.Consumers {
+			_, err := p.q.ExecContext(ctx,
+				`INSERT INTO expense_item_consumers (expense_id, position, user_id)
+				 VALUES ($1, $2, $3)`,
+				e.ID,
+				i,
+				userID,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (p sqlQueries) deleteExpense(ctx context.Context, expenseID string) error {
+	// splits, payers and items are removed by ON DELETE CASCADE
+	_, err := p.q.ExecContext(ctx, `
+		DELETE FROM expenses
+		WHERE id = $1
+	`, expenseID)
+	return err
+}
+
+// settlementColumns is the SELECT list scanned by scanSettlement.
+const settlementColumns = `id, group_id, from_user_id, to_user_id, amount, currency, created_at`
+
+func scanSettlement(row interface{ Scan(...any) error }) (SettlementView, error) {
+	var s SettlementView
+	var groupID sql.NullString
+	err := row.Scan(&s.ID, &groupID, &s.FromUserID, &s.ToUserID, &s.Amount, &s.Currency, &s.CreatedAt)
+	if err != nil {
+		return SettlementView{}, err
+	}
+	s.GroupID = groupID.String
+	s.Amount.Currency = s.Currency
+	return s, nil
+}
+
+func (p sqlQueries) settlement(ctx context.Context, settlementID string) (SettlementView, bool, error) {
+	s, err := scanSettlement(p.q.QueryRowContext(ctx, `
+		SELECT `+settlementColumns+`
+		FROM settlements
+		WHERE id = $1
+	`, settlementID))
+	if err == sql.ErrNoRows {
+		return SettlementView{}, false, nil
+	}
+	if err != nil {
+		return SettlementView{}, false, err
+	}
+	return s, true, nil
+}
+
+func (p sqlQueries) listSettlements(ctx context.Context, filter SettlementFilter, after *pageKey, limit int) ([]SettlementView, error) {
+	query := `
+		SELECT ` + settlementColumns + `
+		FROM settlements
+		WHERE TRUE`
+	args := []any{}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if filter.GroupID != "" {
+		query += ` AND group_id = ` + arg(filter.GroupID)
+	}
+	switch {
+	case filter.CounterpartyID != "":
+		user, counterparty := arg(filter.UserID), arg(filter.CounterpartyID)
+		query += ` AND ((from_user_id = ` + user + ` AND to_user_id = ` + counterparty + `)
+			OR (from_user_id = ` + counterparty + ` AND to_user_id = ` + user + `))`
+	case filter.UserID != "":
+		user := arg(filter.UserID)
+		query += ` AND (from_user_id = ` + user + ` OR to_user_id = ` + user + `)`
+	}
+	if !filter.From.IsZero() {
+		query += ` AND created_at >= ` + arg(p.d.timeArg(filter.From))
+	}
+	if !filter.To.IsZero() {
+		query += ` AND created_at < ` + arg(p.d.timeArg(filter.To))
+	}
+	if after != nil {
+		query += ` AND (created_at, id) < (` + arg(p.d.timeArg(after.createdAt)) + `, ` + arg(after.id) + `)`
+	}
+	query += ` ORDER BY created_at DESC, id DESC LIMIT ` + arg(limit)
+
+	return p.querySettlements(ctx, query, args...)
+}
+
+func (p sqlQueries) allSettlements(ctx context.Context) ([]SettlementView, error) {
+	return p.querySettlements(ctx, `
+		SELECT `+settlementColumns+`
+		FROM settlements
+		ORDER BY created_at, id
+	`)
+}
+
+func (p sqlQueries) querySettlements(ctx context.Context, query string, args ...any) ([]SettlementView, error) {
+	rows, err := p.q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	settlements := []SettlementView{}
+	for rows.Next() {
+		s, err := scanSettlement(rows)
+		if err != nil {
+			return nil, err
+		}
+		settlements = append(settlements, s)
+	}
+	return settlements, rows.Err()
+}
+
+func (p sqlQueries) insertSettlement(ctx context.Context, s SettlementView) error {
+	_, err := p.q.ExecContext(ctx, `
+		INSERT INTO settlements (id, group_id, from_user_id, to_user_id, amount, currency)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`,
+		s.ID,
+		scopeParam(s.GroupID),
+		s.FromUserID,
+		s.ToUserID,
+		s.Amount,
+		s.Currency,
+	)
+	return err
+}
@@ -0,0 +1,53 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// SQLiteStore keeps the ledger in a SQLite database, for small
+// self-hosted deployments where running PostgreSQL is not worth it. Its
+// schema is in db/migrations/sqlite.
+//
+// The caller opens db with a SQLite driver and should enable foreign keys
+// (PRAGMA foreign_keys = ON) on its connections. SQLite allows one writer
+// at a time, so the store uses a single connection: transactions queue up
+// instead of failing as busy.
+type SQLiteStore struct {
+	sqlQueries
+	db *sql.DB
+}
+
+// NewSQLiteStore returns a store that runs on db.
+func NewSQLiteStore(db *sql.DB) *SQLiteStore {
+	db.SetMaxOpenConns(1)
+	return &SQLiteStore{sqlQueries: sqlQueries{q: db, d: sqliteDialect}, db: db}
+}
+
+func (s *SQLiteStore) withTx(ctx context.Context, fn func(tx storeTx) error) error {
+	// SQLite transactions are always serializable
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(sqlQueries{q: tx, d: sqliteDialect}); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sqliteTimeFormat is how the SQLite schema stores timestamps.
+const sqliteTimeFormat = "2006-01-02 15:04:05.000"
+
+var sqliteDialect = &sqlDialect{
+	now: `strftime('%Y-%m-%d %H:%M:%f', 'now')`,
+	// the single connection already keeps other writers out
+	lockRows:   "",
+	balanceKey: "(COALESCE(group_id, ''), from_user_id, to_user_id)",
+	timeArg:    func(t time.Time) any { return t.UTC().Format(sqliteTimeFormat) },
+	// IDs are plain TEXT
+	validID: func(string) bool { return true },
+}
@@ -0,0 +1,176 @@
+package ledger
+
+import (
+	"context"
+	"database/sql"
+	"reflect"
+	"testing"
+
+	_ "modernc.org/sqlite"
+
+	migrations "github.com/mukesh1352/splitwise-backend/db"
+)
+
+// newSQLiteLedger returns a ledger on an in-memory SQLite database with
+// users u1, u2 and u3, all members of group g1.
+func newSQLiteLedger(t *testing.T) *Ledger {
+	t.Helper()
+	ctx := context.Background()
+
+	db, err := sql.Open("sqlite", "file::memory:?_pragma=foreign_keys(1)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store := NewSQLiteStore(db)
+	if _, err := migrations.Up(ctx, db, migrations.SQLite); err != nil {
+		t.Fatal(err)
+	}
+	_, err = db.ExecContext(ctx, `
+		INSERT INTO users (id, name, email) VALUES
+			('u1', 'User One', 'u1@test.com'),
+			('u2', 'User Two', 'u2@test.com'),
+			('u3', 'User Three', 'u3@test.com');
+		INSERT INTO groups (id, name) VALUES ('g1', 'Trip');
+		INSERT INTO group_members (group_id, user_id) VALUES
+			('g1', 'u1'), ('g1', 'u2'), ('g1', 'u3');
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewWithStore(store)
+}
+
+func TestSQLiteStore_DirectScopeUpsert(t *testing.T) {
+	ctx := context.Background()
+	l := newSQLiteLedger(t)
+
+	// the second expense must update the NULL-scoped row, not add one
+	for _, id := range []string{"e1", "e2"} {
+		if err := l.CreateExpense(ctx, equalExpense(id, DirectScope, "u1", 10000, "u1", "u2")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, err := l.GetUserBalances(ctx, "u2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []BalanceView{balanceRow(DirectScope, "u2", "u1", inr(10000))}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("balances = %+v, want %+v", got, want)
+	}
+}
+
+func TestSQLiteStore_ExpenseRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	l := newSQLiteLedger(t)
+
+	input := ExpenseInput{
+		ExpenseID:    "e1",
+		GroupID:      "g1",
+		PaidBy:       "u1",
+		TotalAmount:  inr(5750),
+		SplitType:    SplitItemized,
+		Participants: []string{"u1", "u2"},
+		Items: []ItemInput{
+			{Name: "Pizza", Price: inr(4000), Consumers: []string{"u1", "u2"}},
+			{Name: "Wine", Price: inr(1000), Consumers: []string{"u2"}},
+		},
+		Tax:         inr(750),
+		Description: "Dinner",
+	}
+	if err := l.CreateExpense(ctx, input); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := l.GetExpense(ctx, "e1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Amount != inr(5750) || e.Tax != inr(750) || e.ExchangeRate != "1.0000000000" || e.CreatedAt.IsZero() {
+		t.Errorf("unexpected expense: %+v", e)
+	}
+	if len(e.Items) != 2 || !reflect.DeepEqual(e.Items[1].Consumers, []string{"u2"}) {
+		t.Errorf("unexpected items: %+v", e.Items)
+	}
+
+	if err := l.DeleteExpense(ctx, "e1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.GetExpense(ctx, "e1"); err != ErrExpenseNotFound {
+		t.Errorf("GetExpense() after delete: error = %v, want %v", err, ErrExpenseNotFound)
+	}
+}
+
+func TestSQLiteStore_AmountsStoredAsText(t *testing.T) {
+	ctx := context.Background()
+	l := newSQLiteLedger(t)
+
+	if err := l.CreateExpense(ctx, equalExpense("e1", "g1", "u1", 10000, "u1", "u2", "u3")); err != nil {
+		t.Fatal(err)
+	}
+
+	db := l.store.(*SQLiteStore).db
+	rows, err := db.QueryContext(ctx, `SELECT typeof(amount), amount FROM expense_splits ORDER BY user_id`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var got []string
+	for rows.Next() {
+		var kind, amount string
+		if err := rows.Scan(&kind, &amount); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, kind+" "+amount)
+	}
+	if want := []string{"text 33.34", "text 33.33", "text 33.33"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("stored splits = %v, want %v", got, want)
+	}
+}
+
+func TestSQLiteStore_Pagination(t *testing.T) {
+	ctx := context.Background()
+	l := newSQLiteLedger(t)
+
+	for _, id := range []string{"e1", "e2", "e3"} {
+		if err := l.CreateExpense(ctx, equalExpense(id, "g1", "u1", 1000, "u1", "u2")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	seen := map[string]bool{}
+	filter := ExpenseFilter{GroupID: "g1", Limit: 2}
+	for pages := 0; ; pages++ {
+		if pages > 2 {
+			t.Fatal("pagination did not end")
+		}
+		page, err := l.ListExpenses(ctx, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range page.Expenses {
+			if seen[e.ID] {
+				t.Errorf("expense %s listed twice", e.ID)
+			}
+			seen[e.ID] = true
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	if len(seen) != 3 {
+		t.Errorf("listed %d expenses, want 3", len(seen))
+	}
+
+	discrepancies, err := l.VerifyBalances(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(discrepancies) != 0 {
+		t.Errorf("unexpected discrepancies: %+v", discrepancies)
+	}
+}
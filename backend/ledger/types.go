@@ -7,25 +7,58 @@ const (
 	SplitEqual      SplitType = "EQUAL"
 	SplitExact      SplitType = "EXACT"
 	SplitPercentage SplitType = "PERCENT"
+	SplitShares     SplitType = "SHARES"
+	SplitAdjustment SplitType = "ADJUSTMENT"
+	SplitItemized   SplitType = "ITEMIZED"
 )
 
 // SplitInput represents how much a single participant owes.
 type SplitInput struct {
 	UserID     string  `json:"user_id"`
-	Amount     float64 `json:"amount,omitempty"`
+	Amount     Money   `json:"amount,omitzero"`
 	Percentage float64 `json:"percentage,omitempty"`
+	Shares     float64 `json:"shares,omitempty"`
+	// Adjustment is added to (or, when negative, taken from) the user's
+	// equal share in an ADJUSTMENT split.
+	Adjustment Money `json:"adjustment,omitzero"`
 }
 
+// ItemInput is one line of an itemized receipt and the participants who
+// consumed it.
+type ItemInput struct {
+	Name      string   `json:"name"`
+	Price     Money    `json:"price"`
+	Consumers []string `json:"consumers"`
+}
+
+// PayerInput represents how much a single payer put towards an expense.
+type PayerInput struct {
+	UserID string `json:"user_id"`
+	Amount Money  `json:"amount"`
+}
 
 // ExpenseInput represents the input required to create an expense.
+// An expense is paid either by a single PaidBy user or by several Payers
+// whose amounts sum to TotalAmount. With Payers, PaidBy is optional and
+// names the primary payer.
+//
+// An expense belongs to GroupID, whose members must include everyone it
+// names, or is marked Direct: a non-group expense between any active
+// users, recorded in the direct scope.
 type ExpenseInput struct {
-	ExpenseID    string     `json:"expense_id"`
-	GroupID      string     `json:"group_id"`
-	PaidBy       string     `json:"paid_by"`
-	TotalAmount  float64    `json:"total_amount"`
-	SplitType    SplitType  `json:"split_type"`
-	Participants []string   `json:"participants"`
+	ExpenseID    string       `json:"expense_id"`
+	GroupID      string       `json:"group_id"`
+	Direct       bool         `json:"direct,omitempty"`
+	PaidBy       string       `json:"paid_by"`
+	Payers       []PayerInput `json:"payers,omitempty"`
+	TotalAmount  Money        `json:"total_amount"`
+	Currency     string       `json:"currency,omitempty"`      // defaults to the group's base currency
+	ExchangeRate string       `json:"exchange_rate,omitempty"` // units of base currency per unit of Currency
+	SplitType    SplitType    `json:"split_type"`
+	Participants []string     `json:"participants"`
 	Splits       []SplitInput `json:"splits,omitempty"`
-	Description  string     `json:"description,omitempty"`
+	Items        []ItemInput  `json:"items,omitempty"` // ITEMIZED only
+	Tax          Money        `json:"tax,omitzero"`    // ITEMIZED only
+	Tip          Money        `json:"tip,omitzero"`    // ITEMIZED only
+	Description  string       `json:"description,omitempty"`
 }
-
@@ -0,0 +1,168 @@
+package ledger
+
+import (
+	"context"
+	"net/mail"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUserNotFound is returned for a user ID that does not exist or
+	// belongs to a deactivated user.
+	ErrUserNotFound error = notFound("user_not_found", "user not found")
+	// ErrEmailTaken is returned when another user, active or deactivated,
+	// already has the email.
+	ErrEmailTaken error = conflict("email_taken", "email is already in use")
+	// ErrUserHasBalances is returned when deactivating a user who still
+	// owes or is owed money.
+	ErrUserHasBalances error = conflict("user_has_balances", "user has outstanding balances")
+)
+
+// maxNameLength matches the users.name and groups.name columns.
+const maxNameLength = 100
+
+// UserInput holds the fields of a new user.
+type UserInput struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// UserUpdate holds the fields to change on a user; nil fields are kept.
+type UserUpdate struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+}
+
+// CreateUser adds a user with a new ID and returns it. The email is
+// trimmed and lower-cased; ErrEmailTaken is returned when another user
+// already has it.
+func (l *Ledger) CreateUser(ctx context.Context, input UserInput) (UserView, error) {
+	name, err := normalizeName(input.Name)
+	if err != nil {
+		return UserView{}, err
+	}
+	email, err := normalizeEmail(input.Email)
+	if err != nil {
+		return UserView{}, err
+	}
+	user := UserView{ID: uuid.NewString(), Name: name, Email: email}
+
+	err = l.withTx(ctx, func(tx storeTx) error {
+		if err := checkEmailFree(ctx, tx, email, ""); err != nil {
+			return err
+		}
+		return tx.insertUser(ctx, user)
+	})
+	if err != nil {
+		return UserView{}, err
+	}
+	return user, nil
+}
+
+// UpdateUser changes an active user's name or email and returns the user
+// as stored. The email is normalized as in CreateUser.
+func (l *Ledger) UpdateUser(ctx context.Context, userID string, update UserUpdate) (UserView, error) {
+	if !l.store.validID(userID) {
+		return UserView{}, ErrUserNotFound
+	}
+
+	var name, email string
+	var err error
+	if update.Name != nil {
+		if name, err = normalizeName(*update.Name); err != nil {
+			return UserView{}, err
+		}
+	}
+	if update.Email != nil {
+		if email, err = normalizeEmail(*update.Email); err != nil {
+			return UserView{}, err
+		}
+	}
+
+	var user UserView
+	err = l.withTx(ctx, func(tx storeTx) error {
+		var err error
+		if user, err = tx.user(ctx, userID); err != nil {
+			return err
+		}
+		if update.Name != nil {
+			user.Name = name
+		}
+		if update.Email != nil {
+			if err := checkEmailFree(ctx, tx, email, userID); err != nil {
+				return err
+			}
+			user.Email = email
+		}
+		return tx.updateUser(ctx, user)
+	})
+	if err != nil {
+		return UserView{}, err
+	}
+	return user, nil
+}
+
+// DeactivateUser retires a user. Their expenses and settlements are kept,
+// but they no longer appear among users or group members, and their email
+// stays reserved. A user who still owes or is owed money in any scope
+// cannot be deactivated: ErrUserHasBalances is returned.
+func (l *Ledger) DeactivateUser(ctx context.Context, userID string) error {
+	if !l.store.validID(userID) {
+		return ErrUserNotFound
+	}
+
+	return l.withTx(ctx, func(tx storeTx) error {
+		if _, err := tx.user(ctx, userID); err != nil {
+			return err
+		}
+		balances, err := tx.userBalances(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(balances) > 0 {
+			return ErrUserHasBalances
+		}
+		return tx.deactivateUser(ctx, userID)
+	})
+}
+
+// checkEmailFree returns ErrEmailTaken when a user other than userID has
+// the email.
+func checkEmailFree(ctx context.Context, tx storeTx, email, userID string) error {
+	ownerID, ok, err := tx.userIDByEmail(ctx, email)
+	if err != nil {
+		return err
+	}
+	if ok && ownerID != userID {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+func normalizeName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", NewValidationError("name", "name must be provided")
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		return "", NewValidationError("name", "name must be at most 100 characters")
+	}
+	return name, nil
+}
+
+// normalizeEmail trims and lower-cases an email and checks that it is a
+// bare address.
+func normalizeEmail(email string) (string, error) {
+	email = strings.ToLower(strings.TrimSpace(email))
+	if email == "" {
+		return "", NewValidationError("email", "email must be provided")
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || len(email) > 255 {
+		return "", NewValidationError("email", "email is not a valid address")
+	}
+	return email, nil
+}
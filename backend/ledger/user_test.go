@@ -0,0 +1,111 @@
+package ledger
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestCreateUser_NormalizesEmail(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLedger(t)
+
+	u, err := l.CreateUser(ctx, UserInput{Name: " Asha ", Email: "  Asha@Example.COM "})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Name != "Asha" || u.Email != "asha@example.com" {
+		t.Errorf("CreateUser() = %+v, want trimmed name and lower-case email", u)
+	}
+
+	// emails compare case-insensitively, including pre-existing ones
+	for _, email := range []string{"asha@example.com", "U1@Test.com"} {
+		_, err := l.CreateUser(ctx, UserInput{Name: "Other", Email: email})
+		if !errors.Is(err, ErrEmailTaken) {
+			t.Errorf("CreateUser(%s) error = %v, want %v", email, err, ErrEmailTaken)
+		}
+	}
+	if _, err := l.CreateUser(ctx, UserInput{Name: "Other", Email: "not an email"}); err == nil {
+		t.Error("expected error for an invalid email")
+	}
+}
+
+func TestUpdateUser(t *testing.T) {
+	ctx := context.Background()
+	l, _ := newTestLedger(t)
+
+	u, err := l.CreateUser(ctx, UserInput{Name: "Asha", Email: "asha@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := "Asha R"
+	got, err := l.UpdateUser(ctx, u.ID, UserUpdate{Name: &name})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Name != name || got.Email != u.Email {
+		t.Errorf("UpdateUser() = %+v, want only the name changed", got)
+	}
+
+	// keeping one's own email is not a clash; taking someone else's is
+	same, other := "ASHA@example.com", "u2@test.com"
+	if _, err := l.UpdateUser(ctx, u.ID, UserUpdate{Email: &same}); err != nil {
+		t.Errorf("UpdateUser() with own email: %v", err)
+	}
+	if _, err := l.UpdateUser(ctx, u.ID, UserUpdate{Email: &other}); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("UpdateUser() error = %v, want %v", err, ErrEmailTaken)
+	}
+
+	// IDs are checked by the store, whose IDs need not be UUIDs
+	if _, err := l.UpdateUser(ctx, "u3", UserUpdate{Name: &name}); err != nil {
+		t.Errorf("UpdateUser(u3): %v", err)
+	}
+	if err := l.DeactivateUser(ctx, "u3"); err != nil {
+		t.Errorf("DeactivateUser(u3): %v", err)
+	}
+}
+
+func TestDeactivateUser_RefusedWithBalances(t *testing.T) {
+	ctx := context.Background()
+	l, store := newTestLedger(t)
+
+	u, err := l.CreateUser(ctx, UserInput{Name: "Asha", Email: "asha@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.AddGroupMember("g1", u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.CreateExpense(ctx, equalExpense("e1", "g1", "u1", 10000, "u1", u.ID)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.DeactivateUser(ctx, u.ID); !errors.Is(err, ErrUserHasBalances) {
+		t.Fatalf("DeactivateUser() error = %v, want %v", err, ErrUserHasBalances)
+	}
+
+	if _, err := l.SettleBalance(ctx, "g1", u.ID, "u1", inr(5000)); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.DeactivateUser(ctx, u.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	members, err := l.GetGroupMembers(ctx, "g1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, m := range members {
+		if m.ID == u.ID {
+			t.Error("deactivated user is still listed as a group member")
+		}
+	}
+	if err := l.DeactivateUser(ctx, u.ID); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("second DeactivateUser() error = %v, want %v", err, ErrUserNotFound)
+	}
+	// the email stays reserved
+	if _, err := l.CreateUser(ctx, UserInput{Name: "Asha", Email: u.Email}); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("CreateUser() with a deactivated user's email: error = %v, want %v", err, ErrEmailTaken)
+	}
+}
@@ -2,182 +2,85 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"time"
 
-	"github.com/jackc/pgx/v5/pgxpool"
-	"github.com/jackc/pgx/v5/stdlib"
 	"github.com/joho/godotenv"
 
+	"github.com/mukesh1352/splitwise-backend/api"
+	"github.com/mukesh1352/splitwise-backend/db"
 	"github.com/mukesh1352/splitwise-backend/ledger"
 )
-func enableCORS(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
 
-		if r.Method == http.MethodOptions {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
-
-		next.ServeHTTP(w, r)
-	})
-}
+// defaultRequestTimeout bounds how long a request may keep database work
+// running; override it with REQUEST_TIMEOUT (e.g. "30s").
+const defaultRequestTimeout = 10 * time.Second
 
 func main() {
 	if err := godotenv.Load(); err != nil {
 		log.Println("no .env file found, relying on environment variables")
 	}
 
-	dsn := os.Getenv("DATABASE_URL")
-	if dsn == "" {
-		log.Fatal("DATABASE_URL is not set")
-	}
-
-	pool, err := pgxpool.New(context.Background(), dsn)
+	st, err := openStorage()
 	if err != nil {
-		log.Fatalf("failed to create pgx pool: %v", err)
+		log.Fatal(err)
 	}
-	defer pool.Close()
-	//
-	//converting the pgxpool to *sql
-	sqlDB := stdlib.OpenDBFromPool(pool)
-	defer sqlDB.Close()
+	defer st.close()
 
-	if err := sqlDB.Ping(); err != nil {
-		log.Fatalf("failed to ping database: %v", err)
-	}
-
-	log.Println("database connection established successfully")
+	l := ledger.NewWithStore(st.store)
 
-	l := ledger.New(sqlDB)
-	mux := http.NewServeMux()
-
-	// Get the balances of the users
-	mux.HandleFunc("/balances/user", func(w http.ResponseWriter, r *http.Request) {
-		userID := r.URL.Query().Get("user_id")
-		if userID == "" {
-			http.Error(w, "user_id is required", http.StatusBadRequest)
-			return
-		}
-		balances, err := l.GetUserBalances(userID)
+	// Optional offline exchange rates for foreign-currency expenses
+	if path := os.Getenv("RATES_FILE"); path != "" {
+		rates, err := ledger.LoadRatesFile(path)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			log.Fatalf("failed to load exchange rates: %v", err)
 		}
-		json.NewEncoder(w).Encode(balances)
-	})
+		l.SetRateProvider(rates)
+	}
 
-	// Get the balances for the groups
-	mux.HandleFunc("/balances/groups", func(w http.ResponseWriter, r *http.Request) {
-		groupID := r.URL.Query().Get("group_id")
-		if groupID == "" {
-			http.Error(w, "group_id is required..", http.StatusBadRequest)
-			return
-		}
-		balances, err := l.GetGroupBalances(groupID)
+	// admin subcommands (migrate, verify-balances, rebuild-balances) run
+	// and exit
+	if len(os.Args) > 1 {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		err := runAdmin(ctx, st, l, os.Args[1:])
+		stop()
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		json.NewEncoder(w).Encode(balances)
-	})
-
-	// create the expenses
-	mux.HandleFunc("/expenses", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method is not allowed..", http.StatusMethodNotAllowed)
-			return
+			log.Fatal(err)
 		}
-		var input ledger.ExpenseInput
-		if err := json.NewDecoder(r.Body).Decode(&input); err != nil {
-			http.Error(w, "invalid request body..", http.StatusBadRequest)
-			return
-		}
-		if err := l.CreateExpense(r.Context(), input); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
-			return
-		}
-		w.WriteHeader(http.StatusCreated)
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "Expense created successfully..",
-		})
-	})
-
-	// Get all users
-mux.HandleFunc("/users", func(w http.ResponseWriter, _ *http.Request) {
-	users, err := l.GetUsers()
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
-	json.NewEncoder(w).Encode(users)
-})
 
-// Get all groups
-mux.HandleFunc("/groups", func(w http.ResponseWriter, _ *http.Request) {
-	groups, err := l.GetGroups()
+	migrateOnStart, err := st.autoMigrate()
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+		log.Fatal(err)
 	}
-	json.NewEncoder(w).Encode(groups)
-})
-
-// Get group members
-mux.HandleFunc("/groups/members", func(w http.ResponseWriter, r *http.Request) {
-	groupID := r.URL.Query().Get("group_id")
-	if groupID == "" {
-		http.Error(w, "group_id is required", http.StatusBadRequest)
-		return
-	}
-	users, err := l.GetGroupMembers(groupID)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
-	}
-	json.NewEncoder(w).Encode(users)
-})
-
-	// settling the balance
-	mux.HandleFunc("/settle", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method != http.MethodPost {
-			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
-			return
-		}
-		var request struct {
-			FromUserID string  `json:"from_user_id"`
-			ToUserID   string  `json:"to_user_id"`
-			Amount     float64 `json:"amount"`
-		}
-		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
-			http.Error(w, "invalid request body", http.StatusBadRequest)
-			return
-		}
-		if err := l.SettleBalance(
-			r.Context(),
-			request.FromUserID,
-			request.ToUserID,
-			request.Amount,
-		); err != nil {
-			http.Error(w, err.Error(), http.StatusBadGateway)
-			return
+	if migrateOnStart {
+		applied, err := db.Up(context.Background(), st.db, st.dialect)
+		if err != nil {
+			log.Fatalf("failed to migrate database: %v", err)
 		}
-		w.WriteHeader(http.StatusOK)
-		json.NewEncoder(w).Encode(map[string]string{
-			"status": "Settlement is recorded succesfully..",
-		})
-	})
+		log.Printf("database schema up to date, %d migration(s) applied", len(applied))
+	}
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
-	log.Println("CONNECTED DATABASE =", dsn)
+	timeout := defaultRequestTimeout
+	if value := os.Getenv("REQUEST_TIMEOUT"); value != "" {
+		if timeout, err = time.ParseDuration(value); err != nil || timeout <= 0 {
+			log.Fatalf("invalid REQUEST_TIMEOUT %q", value)
+		}
+	}
+
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           api.NewHandler(l, timeout),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
 	log.Println("Server running on.. : " + port)
-	log.Fatal(http.ListenAndServe(":"+port, enableCORS(mux)))
+	log.Fatal(server.ListenAndServe())
 }
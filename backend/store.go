@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/jackc/pgx/v5/stdlib"
+	_ "modernc.org/sqlite"
+
+	"github.com/mukesh1352/splitwise-backend/db"
+	"github.com/mukesh1352/splitwise-backend/ledger"
+)
+
+// storage is the store the server runs on and, for the SQL stores, the
+// database behind it.
+type storage struct {
+	store   ledger.Store
+	db      *sql.DB // nil for the memory store
+	dialect db.Dialect
+	close   func()
+}
+
+// openStorage opens the store named by the STORE environment variable:
+//
+//	postgres  (default) the database at DATABASE_URL
+//	sqlite    the SQLite file at SQLITE_PATH (default splitwise.db),
+//	          created on first start
+//	memory    an in-process store seeded with the users and group from
+//	          db/seed.sql; everything is lost on exit
+func openStorage() (*storage, error) {
+	switch kind := os.Getenv("STORE"); kind {
+	case "", "postgres":
+		return openPostgres()
+	case "sqlite":
+		return openSQLite()
+	case "memory":
+		store, err := demoStore()
+		if err != nil {
+			return nil, err
+		}
+		log.Println("using the in-memory demo store; data is lost on exit")
+		return &storage{store: store, close: func() {}}, nil
+	default:
+		return nil, fmt.Errorf("unknown STORE %q (expected postgres, sqlite or memory)", kind)
+	}
+}
+
+func openPostgres() (*storage, error) {
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		return nil, fmt.Errorf("DATABASE_URL is not set")
+	}
+
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pgx pool: %w", err)
+	}
+	//
+	//converting the pgxpool to *sql
+	sqlDB := stdlib.OpenDBFromPool(pool)
+	closeAll := func() {
+		sqlDB.Close()
+		pool.Close()
+	}
+
+	if err := sqlDB.Ping(); err != nil {
+		closeAll()
+		return nil, fmt.Errorf("failed to ping database: %w", err)
+	}
+
+	log.Println("database connection established successfully")
+	log.Println("CONNECTED DATABASE =", dsn)
+	return &storage{
+		store:   ledger.NewPostgresStore(sqlDB),
+		db:      sqlDB,
+		dialect: db.Postgres,
+		close:   closeAll,
+	}, nil
+}
+
+func openSQLite() (*storage, error) {
+	path := os.Getenv("SQLITE_PATH")
+	if path == "" {
+		path = "splitwise.db"
+	}
+
+	// foreign keys are off by default in SQLite; the busy timeout covers
+	// other processes (e.g. the sqlite3 shell) holding the file
+	dsn := "file:" + path + "?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)"
+	sqlDB, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open sqlite database: %w", err)
+	}
+
+	log.Println("using sqlite database", path)
+	return &storage{
+		store:   ledger.NewSQLiteStore(sqlDB),
+		db:      sqlDB,
+		dialect: db.SQLite,
+		close:   func() { sqlDB.Close() },
+	}, nil
+}
+
+// autoMigrate reports whether the server applies pending migrations when
+// it starts. AUTO_MIGRATE overrides the default, which is on for SQLite,
+// where the server owns its database file, and off for PostgreSQL, where
+// schema changes are usually rolled out separately.
+func (s *storage) autoMigrate() (bool, error) {
+	if s.db == nil {
+		return false, nil
+	}
+	value := os.Getenv("AUTO_MIGRATE")
+	if value == "" {
+		return s.dialect == db.SQLite, nil
+	}
+	enabled, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid AUTO_MIGRATE %q", value)
+	}
+	return enabled, nil
+}
+
+// demoStore returns a MemoryStore holding the same users and group as
+// db/seed.sql, so the frontend works against it unchanged.
+func demoStore() (*ledger.MemoryStore, error) {
+	const (
+		userOne = "11111111-1111-1111-1111-111111111111"
+		userTwo = "22222222-2222-2222-2222-222222222222"
+		group   = "aaaaaaaa-aaaa-aaaa-aaaa-aaaaaaaaaaaa"
+	)
+
+	store := ledger.NewMemoryStore()
+	if err := store.AddUser(userOne, "User One", "u1@test.com"); err != nil {
+		return nil, err
+	}
+	if err := store.AddUser(userTwo, "User Two", "u2@test.com"); err != nil {
+		return nil, err
+	}
+	if err := store.AddGroup(group, "Test Group", ledger.DefaultCurrency); err != nil {
+		return nil, err
+	}
+	for _, userID := range []string{userOne, userTwo} {
+		if err := store.AddGroupMember(group, userID); err != nil {
+			return nil, err
+		}
+	}
+	return store, nil
+}
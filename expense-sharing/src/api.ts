@@ -1,8 +1,27 @@
 const API_BASE = import.meta.env.VITE_API_BASE as string;
 
+// ApiError carries the backend's error envelope:
+// {"error": {"code", "message", "fields"}}.
+export class ApiError extends Error {
+  code: string;
+  fields: { field: string; message: string; user_ids?: string[] }[];
+
+  constructor(status: number, body: string) {
+    let parsed;
+    try {
+      parsed = JSON.parse(body).error;
+    } catch {
+      parsed = undefined;
+    }
+    super(parsed?.message ?? (body || `request failed with status ${status}`));
+    this.code = parsed?.code ?? "unknown";
+    this.fields = parsed?.fields ?? [];
+  }
+}
+
 async function handle<T>(res: Response): Promise<T> {
   if (!res.ok) {
-    throw new Error(await res.text());
+    throw new ApiError(res.status, await res.text());
   }
   return (await res.json()) as T;
 }
@@ -5,8 +5,29 @@ import type {
   UserView,
   GroupView,
   SplitInput,
+  SplitType,
 } from "../types";
 
+type SplitField = "amount" | "percentage" | "shares" | "adjustment";
+
+// ITEMIZED expenses carry line items instead of per-person split values.
+const splitFields: Record<
+  Exclude<SplitType, "EQUAL" | "ITEMIZED">,
+  SplitField
+> = {
+  EXACT: "amount",
+  PERCENT: "percentage",
+  SHARES: "shares",
+  ADJUSTMENT: "adjustment",
+};
+
+const splitLabels: Record<SplitField, string> = {
+  amount: "Amount",
+  percentage: "Percentage",
+  shares: "Shares",
+  adjustment: "Adjustment",
+};
+
 type Props = {
   onSuccess: () => void;
 };
@@ -33,7 +54,7 @@ export default function CreateExpense({ onSuccess }: Props) {
   /* ---------- Load members ---------- */
   useEffect(() => {
     if (!expense.group_id) return;
-    get<UserView[]>(`/groups/members?group_id=${expense.group_id}`)
+    get<UserView[]>(`/groups/${expense.group_id}/members`)
       .then(setMembers);
   }, [expense.group_id]);
 
@@ -52,7 +73,7 @@ export default function CreateExpense({ onSuccess }: Props) {
   /* ---------- Split updater ---------- */
   const updateSplit = (
     userId: string,
-    field: "amount" | "percentage",
+    field: SplitField,
     value: number
   ) => {
     setExpense(prev => {
@@ -99,6 +120,11 @@ export default function CreateExpense({ onSuccess }: Props) {
     }));
   };
 
+  const splitField =
+    expense.split_type === "EQUAL" || expense.split_type === "ITEMIZED"
+      ? null
+      : splitFields[expense.split_type];
+
   /* ---------- UI ---------- */
   return (
     <div className="section">
@@ -164,6 +190,8 @@ export default function CreateExpense({ onSuccess }: Props) {
             <option value="EQUAL">Equal Split</option>
             <option value="EXACT">Exact Split</option>
             <option value="PERCENT">Percentage Split</option>
+            <option value="SHARES">Shares Split</option>
+            <option value="ADJUSTMENT">Equal Split with Adjustments</option>
           </select>
         </div>
       </fieldset>
@@ -192,12 +220,16 @@ export default function CreateExpense({ onSuccess }: Props) {
       </fieldset>
 
       {/* Split Inputs */}
-      {expense.split_type !== "EQUAL" && (
+      {splitField && (
         <fieldset style={{ marginBottom: "16px" }}>
           <legend>
             {expense.split_type === "EXACT"
               ? "Exact Amounts"
-              : "Percentages"}
+              : expense.split_type === "PERCENT"
+                ? "Percentages"
+                : expense.split_type === "SHARES"
+                  ? "Shares"
+                  : "Adjustments"}
           </legend>
 
           {expense.participants.map(userId => {
@@ -210,19 +242,9 @@ export default function CreateExpense({ onSuccess }: Props) {
                   {user.name}{" "}
                   <input
                     type="number"
-                    placeholder={
-                      expense.split_type === "EXACT"
-                        ? "Amount"
-                        : "Percentage"
-                    }
+                    placeholder={splitLabels[splitField]}
                     onChange={e =>
-                      updateSplit(
-                        userId,
-                        expense.split_type === "EXACT"
-                          ? "amount"
-                          : "percentage",
-                        +e.target.value
-                      )
+                      updateSplit(userId, splitField, +e.target.value)
                     }
                   />
                 </label>
@@ -20,7 +20,7 @@ export default function GroupBalances({ refreshKey }: Props) {
   useEffect(() => {
     if (!groupId) return;
 
-    get<BalanceView[]>(`/balances/groups?group_id=${groupId}`)
+    get<BalanceView[]>(`/groups/${groupId}/balances`)
       .then(setBalances);
   }, [groupId, refreshKey]);
 
@@ -57,7 +57,7 @@ export default function GroupBalances({ refreshKey }: Props) {
                 <tr key={i}>
                   <td>{nameById(b.from_user_id)}</td>
                   <td>{nameById(b.to_user_id)}</td>
-                  <td>₹ {b.amount}</td>
+                  <td>{b.currency} {b.amount}</td>
                 </tr>
               ))}
             </tbody>
@@ -1,6 +1,6 @@
 import { useEffect, useState } from "react";
 import { get, post } from "../api";
-import type { SettlementInput, UserView } from "../types";
+import type { GroupView, SettlementInput, UserView } from "../types";
 
 type Props = {
   onSuccess: () => void;
@@ -8,8 +8,11 @@ type Props = {
 
 export default function SettleBalance({ onSuccess }: Props) {
   const [users, setUsers] = useState<UserView[]>([]);
+  const [groups, setGroups] = useState<GroupView[]>([]);
   const [error, setError] = useState("");
   const [data, setData] = useState<SettlementInput>({
+    settlement_id: crypto.randomUUID(),
+    group_id: "",
     from_user_id: "",
     to_user_id: "",
     amount: 0,
@@ -17,6 +20,7 @@ export default function SettleBalance({ onSuccess }: Props) {
 
   useEffect(() => {
     get<UserView[]>("/users").then(setUsers);
+    get<GroupView[]>("/groups").then(setGroups);
   }, []);
 
   const submit = async () => {
@@ -26,8 +30,10 @@ export default function SettleBalance({ onSuccess }: Props) {
         return;
       }
 
-      await post<void>("/settle", data);
+      await post<void>("/settlements", data);
 
+      // a resubmitted form reuses the ID, so only a new settlement gets a new one
+      setData(prev => ({ ...prev, settlement_id: crypto.randomUUID() }));
       setError("");
       alert("Settlement successful");
       onSuccess();
@@ -44,6 +50,18 @@ export default function SettleBalance({ onSuccess }: Props) {
     <div className="section">
       <h2>Settle Balance</h2>
 
+      <select
+        value={data.group_id}
+        onChange={e =>
+          setData({ ...data, group_id: e.target.value })
+        }
+      >
+        <option value="">No Group</option>
+        {groups.map(g => (
+          <option key={g.id} value={g.id}>{g.name}</option>
+        ))}
+      </select>
+
       <select
         value={data.from_user_id}
         onChange={e =>
@@ -18,7 +18,7 @@ export default function UserBalances({ refreshKey }: Props) {
   useEffect(() => {
     if (!userId) return;
 
-    get<BalanceView[]>(`/balances/user?user_id=${userId}`)
+    get<BalanceView[]>(`/users/${userId}/balances/aggregated`)
       .then(setBalances);
   }, [userId, refreshKey]);
 
@@ -62,7 +62,7 @@ export default function UserBalances({ refreshKey }: Props) {
               {owes.map((b, i) => (
                 <tr key={i}>
                   <td>{nameById(b.to_user_id)}</td>
-                  <td>₹ {b.amount}</td>
+                  <td>{b.currency} {b.amount}</td>
                 </tr>
               ))}
             </tbody>
@@ -84,7 +84,7 @@ export default function UserBalances({ refreshKey }: Props) {
               {owed.map((b, i) => (
                 <tr key={i}>
                   <td>{nameById(b.from_user_id)}</td>
-                  <td>₹ {b.amount}</td>
+                  <td>{b.currency} {b.amount}</td>
                 </tr>
               ))}
             </tbody>
@@ -1,4 +1,4 @@
-export type SplitType = "EQUAL" | "EXACT" | "PERCENT";
+export type SplitType = "EQUAL" | "EXACT" | "PERCENT" | "SHARES" | "ADJUSTMENT" | "ITEMIZED";
 
 export interface UserView {
   id: string;
@@ -8,31 +8,57 @@ export interface UserView {
 export interface GroupView {
   id: string;
   name: string;
+  base_currency: string;
+  simplify_debts: boolean;
+  remainder_strategy: "LARGEST_REMAINDER" | "PAYER_FIRST";
 }
 
 export interface BalanceView {
+  group_id?: string;
   from_user_id: string;
   to_user_id: string;
   amount: number;
+  currency: string;
 }
 
 export interface SplitInput {
   user_id: string;
   amount?: number;
   percentage?: number;
+  shares?: number;
+  adjustment?: number;
+}
+
+export interface PayerInput {
+  user_id: string;
+  amount: number;
+}
+
+export interface ItemInput {
+  name: string;
+  price: number;
+  consumers: string[];
 }
 
 export type ExpenseInput = {
   expense_id: string;
   group_id: string;
+  direct?: boolean;
   paid_by: string;
+  payers?: PayerInput[];
   total_amount: number;
-  split_type: "EQUAL" | "EXACT" | "PERCENT";
+  currency?: string;
+  split_type: SplitType;
   participants: string[];
   splits: SplitInput[]; 
+  items?: ItemInput[];
+  tax?: number;
+  tip?: number;
 };
 
 export interface SettlementInput {
+  settlement_id?: string;
+  group_id: string;
   from_user_id: string;
   to_user_id: string;
   amount: number;